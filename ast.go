@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"go/ast"
 	"go/token"
+	"go/types"
+	"strconv"
 )
 
 func astAssign(left, right ast.Expr) ast.Stmt {
@@ -17,6 +19,14 @@ func astAssign(left, right ast.Expr) ast.Stmt {
 	}
 }
 
+func astDefine(varName string, value ast.Expr) ast.Stmt {
+	return &ast.AssignStmt{
+		Lhs: []ast.Expr{&ast.Ident{Name: varName}},
+		Tok: token.DEFINE,
+		Rhs: []ast.Expr{value},
+	}
+}
+
 func astDeclare(varName string, varType ast.Expr) ast.Stmt {
 	return &ast.DeclStmt{Decl: &ast.GenDecl{
 		Tok: token.VAR,
@@ -44,12 +54,375 @@ func astAddressOf(expr ast.Expr) ast.Expr {
 	}
 }
 
-func astCallConvertFunc(funcName string, args ...ast.Expr) ast.Stmt {
-	return &ast.ExprStmt{
-		X: &ast.CallExpr{
-			Fun:  &ast.Ident{Name: funcName},
-			Args: args,
+// astCallConvertFunc calls the conversion function to fill in the value
+// pointed to by dst from the value pointed to by src.
+func astCallConvertFunc(scope funcScope, fn convertFunc, path errPath, src, dst ast.Expr) ast.Stmt {
+	if fn.Method {
+		return astCallConvertMethod(scope, fn, path, src, dst)
+	}
+
+	args := []ast.Expr{src, dst}
+	if !fn.Ctx.IsZero() {
+		args = append([]ast.Expr{&ast.Ident{Name: varNameCtx}}, args...)
+	}
+	call := &ast.CallExpr{
+		Fun:  &ast.Ident{Name: fn.Name},
+		Args: args,
+	}
+	if !fn.Errors {
+		return &ast.ExprStmt{X: call}
+	}
+
+	// if err := <funcName>(<args>...); err != nil {
+	// 	return fmt.Errorf("<path>: %w", err)
+	// }
+	return &ast.IfStmt{
+		Init: &ast.AssignStmt{
+			Lhs: []ast.Expr{&ast.Ident{Name: varNameErr}},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{call},
+		},
+		Cond: astIsNotNil(&ast.Ident{Name: varNameErr}),
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			scope.returnErr(path),
+		}},
+	}
+}
+
+// astCallConvertMethod is like astCallConvertFunc for conversion functions
+// which are methods on the source struct.
+func astCallConvertMethod(scope funcScope, fn convertFunc, path errPath, src, dst ast.Expr) ast.Stmt {
+	var args []ast.Expr
+	if !fn.Ctx.IsZero() {
+		args = append(args, &ast.Ident{Name: varNameCtx})
+	}
+
+	if fn.Direction == DirFrom {
+		// The receiver is filled in from the argument.
+		//
+		// <dst>.<method>(<src>)
+		call := &ast.CallExpr{
+			Fun:  &ast.SelectorExpr{X: astReceiver(dst), Sel: &ast.Ident{Name: fn.Name}},
+			Args: append(args, src),
+		}
+		if !fn.Errors {
+			return &ast.ExprStmt{X: call}
+		}
+		return &ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.Ident{Name: varNameErr}},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{call},
+			},
+			Cond: astIsNotNil(&ast.Ident{Name: varNameErr}),
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				scope.returnErr(path),
+			}},
+		}
+	}
+
+	// The method allocates and returns the converted value.
+	//
+	// <dst> = *<src>.<method>()
+	call := &ast.CallExpr{
+		Fun:  &ast.SelectorExpr{X: astReceiver(src), Sel: &ast.Ident{Name: fn.Name}},
+		Args: args,
+	}
+	if !fn.Errors {
+		return astAssign(astDeref(dst), &ast.StarExpr{X: call})
+	}
+
+	// {
+	// 	r, err := <src>.<method>()
+	// 	if err != nil {
+	// 		return fmt.Errorf("<path>: %w", err)
+	// 	}
+	// 	<dst> = *r
+	// }
+	return &ast.BlockStmt{List: []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{
+				&ast.Ident{Name: varNameResult},
+				&ast.Ident{Name: varNameErr},
+			},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{call},
+		},
+		&ast.IfStmt{
+			Cond: astIsNotNil(&ast.Ident{Name: varNameErr}),
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				scope.returnErr(path),
+			}},
+		},
+		astAssign(astDeref(dst), &ast.StarExpr{X: &ast.Ident{Name: varNameResult}}),
+	}}
+}
+
+// astDeref returns the expression for the value pointed to by expr. Taking
+// the address of a value and then dereferencing it is simplified to the value.
+func astDeref(expr ast.Expr) ast.Expr {
+	if u, ok := expr.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		return u.X
+	}
+	return &ast.StarExpr{X: expr}
+}
+
+// astReceiver returns the expression to use as the receiver of a method call
+// given a pointer to the receiver. Taking the address is not necessary because
+// Go will take the address of addressable values when calling methods.
+func astReceiver(ptr ast.Expr) ast.Expr {
+	if u, ok := ptr.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		return u.X
+	}
+	return ptr
+}
+
+// funcScope describes the generated function that statements are being added
+// to.
+type funcScope struct {
+	// Errors is true when the function returns an error.
+	Errors bool
+
+	// Results are returned along with an error, before the error.
+	Results []ast.Expr
+
+	imports *imports
+}
+
+// returnErr returns a statement which returns err from the function, with the
+// path added for context.
+func (f funcScope) returnErr(path errPath) ast.Stmt {
+	if !f.Errors {
+		panic("function does not return errors")
+	}
+
+	// return fmt.Errorf("<path>: %w", <path args>..., err)
+	results := append(append([]ast.Expr{}, f.Results...), f.wrapErr(path))
+	return &ast.ReturnStmt{Results: results}
+}
+
+// handleErr returns a statement which handles err using the policy, or returns
+// it from the function when the policy is not set.
+func (f funcScope) handleErr(onError onErrorPolicy, path errPath) ast.Stmt {
+	if onError != onErrorPanic {
+		return f.returnErr(path)
+	}
+
+	// panic(fmt.Errorf("<path>: %w", <path args>..., err))
+	return &ast.ExprStmt{X: &ast.CallExpr{
+		Fun:  &ast.Ident{Name: "panic"},
+		Args: []ast.Expr{f.wrapErr(path)},
+	}}
+}
+
+// wrapErr returns the expression which wraps err with the path added for
+// context.
+func (f funcScope) wrapErr(path errPath) ast.Expr {
+	f.imports.Add("", "fmt")
+
+	// fmt.Errorf("<path>: %w", <path args>..., err)
+	args := []ast.Expr{
+		&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path.Format + ": %w")},
+	}
+	args = append(args, path.Args...)
+	args = append(args, &ast.Ident{Name: varNameErr})
+	return &ast.CallExpr{
+		Fun: &ast.SelectorExpr{
+			X:   &ast.Ident{Name: f.imports.AliasFor("fmt")},
+			Sel: &ast.Ident{Name: "Errorf"},
+		},
+		Args: args,
+	}
+}
+
+// returnNewErr returns the statements which create a new error using format
+// and args, and return it from the function with the path added for context.
+func (f funcScope) returnNewErr(path errPath, format string, args ...ast.Expr) []ast.Stmt {
+	f.imports.Add("", "fmt")
+
+	// err := fmt.Errorf(<format>, <args>...)
+	// return fmt.Errorf("<path>: %w", <path args>..., err)
+	return []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{&ast.Ident{Name: varNameErr}},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{&ast.CallExpr{
+				Fun: &ast.SelectorExpr{
+					X:   &ast.Ident{Name: f.imports.AliasFor("fmt")},
+					Sel: &ast.Ident{Name: "Errorf"},
+				},
+				Args: append([]ast.Expr{
+					&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(format)},
+				}, args...),
+			}},
 		},
+		f.returnErr(path),
+	}
+}
+
+// assignOptions are the per field settings which change how a value is
+// assigned.
+type assignOptions struct {
+	// DeepCopy is true when pointers are assigned a pointer to a copy of the
+	// value they point to, instead of sharing it with the source.
+	DeepCopy bool
+
+	// AllocateNil is true when a nil slice or map is converted to an empty
+	// one, instead of nil.
+	AllocateNil bool
+
+	// NilPointer is how the left side is assigned when it is assigned from a
+	// nil pointer.
+	NilPointer nilPointerPolicy
+
+	// CheckConvert is true when a type conversion returns an error if the
+	// converted value is not equal to the original value.
+	CheckConvert bool
+
+	// CheckNegativeRight is true when the conversion also returns an error
+	// if the right value is negative, because the left type is unsigned.
+	// CheckNegativeLeft is true when it returns an error if the converted
+	// value is negative, because the right type is unsigned.
+	CheckNegativeRight bool
+	CheckNegativeLeft  bool
+
+	// OnError is how errors from builtin conversions are handled. The zero
+	// value returns them from the function.
+	OnError onErrorPolicy
+}
+
+// newIfPointerNotNil returns the statement which runs body when the right
+// pointer is not nil, and otherwise assigns left using the nil pointer policy.
+func newIfPointerNotNil(opts assignOptions, left, leftType, right ast.Expr, body ...ast.Stmt) ast.Stmt {
+	return newIfElseNilPolicy(opts, astIsNotNil(right), left, leftType, body...)
+}
+
+// isNilableTypeExpr returns true if the type expression is a pointer, slice
+// or map type, which can be assigned nil. Arrays can not be nil.
+func isNilableTypeExpr(expr ast.Expr) bool {
+	switch x := expr.(type) {
+	case *ast.StarExpr, *ast.MapType:
+		return true
+	case *ast.ArrayType:
+		return x.Len == nil
+	}
+	return false
+}
+
+// newIfElseNilPolicy returns the statement which runs body when cond is true,
+// and otherwise assigns left using the nil pointer policy.
+func newIfElseNilPolicy(opts assignOptions, cond, left, leftType ast.Expr, body ...ast.Stmt) ast.Stmt {
+	stmt := &ast.IfStmt{
+		Cond: cond,
+		Body: &ast.BlockStmt{List: body},
+	}
+
+	switch opts.NilPointer.Mode {
+	case nilPointerKeep:
+		// Leave <left> unchanged.
+	case nilPointerFunc:
+		// <left> = <func>()
+		stmt.Else = &ast.BlockStmt{List: []ast.Stmt{
+			astAssign(left, &ast.CallExpr{Fun: &ast.Ident{Name: opts.NilPointer.Func}}),
+		}}
+	case nilPointerDefault:
+		// <left> = <default>
+		stmt.Else = &ast.BlockStmt{List: []ast.Stmt{
+			astAssign(left, opts.NilPointer.Default),
+		}}
+	default:
+		if isNilableTypeExpr(leftType) {
+			// <left> = nil
+			stmt.Else = &ast.BlockStmt{List: []ast.Stmt{
+				astAssign(left, &ast.Ident{Name: "nil"}),
+			}}
+		} else {
+			// var x <leftType>
+			// <left> = x
+			stmt.Else = &ast.BlockStmt{List: []ast.Stmt{
+				astDeclare(varNamePlaceholder, leftType),
+				astAssign(left, &ast.Ident{Name: varNamePlaceholder}),
+			}}
+		}
+	}
+	return stmt
+}
+
+// astIsZero returns the expression which is true when expr, of type typ, is
+// the zero value, or nil if the zero value can not be compared. typeExpr is
+// used to write the zero value of structs and arrays.
+func astIsZero(expr ast.Expr, typ types.Type, typeExpr ast.Expr) ast.Expr {
+	switch x := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case x.Info()&types.IsBoolean != 0:
+			// !<expr>
+			return &ast.UnaryExpr{Op: token.NOT, X: expr}
+		case x.Info()&types.IsString != 0:
+			// <expr> == ""
+			return &ast.BinaryExpr{X: expr, Op: token.EQL, Y: &ast.BasicLit{Kind: token.STRING, Value: `""`}}
+		case x.Info()&types.IsNumeric != 0:
+			// <expr> == 0
+			return &ast.BinaryExpr{X: expr, Op: token.EQL, Y: &ast.BasicLit{Kind: token.INT, Value: "0"}}
+		}
+	case *types.Pointer, *types.Slice, *types.Map, *types.Interface, *types.Chan, *types.Signature:
+		// <expr> == nil
+		return &ast.BinaryExpr{X: expr, Op: token.EQL, Y: &ast.Ident{Name: "nil"}}
+	case *types.Struct, *types.Array:
+		if !types.Comparable(typ) {
+			return nil
+		}
+		// <expr> == (<typeExpr>{})
+		return &ast.BinaryExpr{
+			X:  expr,
+			Op: token.EQL,
+			Y:  &ast.ParenExpr{X: &ast.CompositeLit{Type: typeExpr}},
+		}
+	}
+	return nil
+}
+
+// newIfZeroDefault returns the statement which assigns the default value to
+// left when isZero is true, and otherwise runs stmt.
+func newIfZeroDefault(isZero ast.Expr, left ast.Expr, defaultValue ast.Expr, stmt ast.Stmt) ast.Stmt {
+	// if <isZero> {
+	// 	<left> = <defaultValue>
+	// } else {
+	// 	<stmt>
+	// }
+	elseStmt, ok := stmt.(*ast.BlockStmt)
+	if !ok {
+		elseStmt = &ast.BlockStmt{List: []ast.Stmt{stmt}}
+	}
+	return &ast.IfStmt{
+		Cond: isZero,
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			astAssign(left, defaultValue),
+		}},
+		Else: elseStmt,
+	}
+}
+
+// errPath is the location of the value being converted, used to add context to
+// errors returned by the generated functions. Format and Args are the
+// arguments to fmt.Errorf.
+type errPath struct {
+	Format string
+	Args   []ast.Expr
+}
+
+func newErrPath(fieldName string) errPath {
+	return errPath{Format: fieldName}
+}
+
+// Index returns the path of an element of a slice or map, where idx is the
+// name of the variable holding the index or key.
+func (p errPath) Index(verb string, idx string) errPath {
+	args := make([]ast.Expr, len(p.Args), len(p.Args)+1)
+	copy(args, p.Args)
+	return errPath{
+		Format: p.Format + "[" + verb + "]",
+		Args:   append(args, &ast.Ident{Name: idx}),
 	}
 }
 
@@ -68,9 +441,9 @@ func newPointerTo(id string) *ast.StarExpr {
 	}
 }
 
-func newIfNilReturn(cmpID string) ast.Stmt {
+func newIfNilReturn(cmpID string, results ...ast.Expr) ast.Stmt {
 	// if <cmpID> == nil {
-	// 	return
+	// 	return <results>
 	// }
 	return &ast.IfStmt{
 		Cond: &ast.BinaryExpr{
@@ -79,18 +452,21 @@ func newIfNilReturn(cmpID string) ast.Stmt {
 			Y:  &ast.Ident{Name: "nil"},
 		},
 		Body: &ast.BlockStmt{List: []ast.Stmt{
-			&ast.ReturnStmt{},
+			&ast.ReturnStmt{Results: results},
 		}},
 	}
 }
 
 // TODO: do the pointer stuff with go/types instead like everything else now?
 func newAssignStmtConvertible(
+	scope funcScope,
+	opts assignOptions,
 	left ast.Expr,
 	leftType ast.Expr,
 	right ast.Expr,
 	rightType ast.Expr,
-	convertFuncName string,
+	convertFunc convertFunc,
+	path errPath,
 ) ast.Stmt {
 	leftPtrType, leftPtr := leftType.(*ast.StarExpr)
 	_, rightPtr := rightType.(*ast.StarExpr)
@@ -105,7 +481,7 @@ func newAssignStmtConvertible(
 		// Value to Value
 		//
 		// <convertFuncName>(&<right>, &<left>)
-		return astCallConvertFunc(convertFuncName,
+		return astCallConvertFunc(scope, convertFunc, path,
 			astAddressOf(right),
 			astAddressOf(left))
 	case !leftPtr && rightPtr:
@@ -113,15 +489,14 @@ func newAssignStmtConvertible(
 		//
 		// if <right> != nil {
 		// 	<convertFuncName>(<right>, &<left>)
+		// } else {
+		// 	<nil pointer policy>
 		// }
-		return &ast.IfStmt{
-			Cond: astIsNotNil(right),
-			Body: &ast.BlockStmt{List: []ast.Stmt{
-				astCallConvertFunc(convertFuncName,
-					right,
-					astAddressOf(left)),
-			}},
-		}
+		return newIfPointerNotNil(opts, left, leftType, right,
+			astCallConvertFunc(scope, convertFunc, path,
+				right,
+				astAddressOf(left)),
+		)
 	case leftPtr && !rightPtr:
 		// Value to Pointer
 		// var <varTarget> <typeTarget>
@@ -129,39 +504,72 @@ func newAssignStmtConvertible(
 		// <left> = &<varTarget>
 		return &ast.BlockStmt{List: []ast.Stmt{
 			astDeclare(varNamePlaceholder, leftRealType),
-			astCallConvertFunc(convertFuncName,
+			astCallConvertFunc(scope, convertFunc, path,
 				astAddressOf(right),
 				astAddressOf(&ast.Ident{Name: varNamePlaceholder})),
 			astAssign(left, newAddressOf(varNamePlaceholder)),
 		}}
 	case leftPtr && rightPtr:
 		// Pointer to Pointer
-		return &ast.IfStmt{
-			Cond: astIsNotNil(right),
-			Body: &ast.BlockStmt{List: []ast.Stmt{
-				astDeclare(varNamePlaceholder, leftRealType),
-				astCallConvertFunc(convertFuncName,
-					right,
-					astAddressOf(&ast.Ident{Name: varNamePlaceholder})),
-				astAssign(left, newAddressOf(varNamePlaceholder)),
-			}},
-		}
+		return newIfPointerNotNil(opts, left, leftType, right,
+			astDeclare(varNamePlaceholder, leftRealType),
+			astCallConvertFunc(scope, convertFunc, path,
+				right,
+				astAddressOf(&ast.Ident{Name: varNamePlaceholder})),
+			astAssign(left, newAddressOf(varNamePlaceholder)),
+		)
 	default:
 		panic("impossible")
 	}
 }
 
 func newAssignStmtSlice(
+	scope funcScope,
+	opts assignOptions,
 	left ast.Expr,
 	leftType ast.Expr,
 	leftElemType ast.Expr,
 	right ast.Expr,
 	rightElemType ast.Expr,
-	convertFuncName string,
+	convertFunc convertFunc,
+	userElemFunc valueFunc,
+	elemBuiltin builtinFunc,
 	direct bool,
 	convert bool,
+	path errPath,
 ) ast.Stmt {
-	return &ast.BlockStmt{List: []ast.Stmt{
+	elemPath := path.Index("%d", "i")
+
+	leftElem := &ast.IndexExpr{
+		X:     left,
+		Index: &ast.Ident{Name: "i"},
+	}
+	rightElem := &ast.IndexExpr{
+		X:     right,
+		Index: &ast.Ident{Name: "i"},
+	}
+
+	var elemStmt ast.Stmt
+	if userElemFunc.Name != "" {
+		// <left>[i] = <userElemFunc>(<right>[i])
+		elemStmt = newAssignStmtUserFunc(scope, leftElem, rightElem, userElemFunc, elemPath)
+	} else {
+		elemStmt = newAssignStmt(
+			scope,
+			opts,
+			leftElem,
+			leftElemType,
+			rightElem,
+			rightElemType,
+			convertFunc,
+			elemBuiltin,
+			direct,
+			convert,
+			elemPath,
+		)
+	}
+
+	return newAllocateCollection(opts, left, right, []ast.Stmt{
 		// <left> = make(<leftType>, len(<right>))
 		&ast.AssignStmt{
 			Tok: token.ASSIGN,
@@ -183,41 +591,72 @@ func newAssignStmtSlice(
 		// 	<left>[i] ??assign?? <right>[i]
 		// }
 		&ast.RangeStmt{
-			Key: &ast.Ident{Name: "i"},
-			Tok: token.DEFINE,
-			X:   right,
-			Body: &ast.BlockStmt{List: []ast.Stmt{
-				newAssignStmt(
-					&ast.IndexExpr{
-						X:     left,
-						Index: &ast.Ident{Name: "i"},
-					},
-					leftElemType,
-					&ast.IndexExpr{
-						X:     right,
-						Index: &ast.Ident{Name: "i"},
-					},
-					rightElemType,
-					convertFuncName,
-					direct,
-					convert,
-				),
-			}},
+			Key:  &ast.Ident{Name: "i"},
+			Tok:  token.DEFINE,
+			X:    right,
+			Body: &ast.BlockStmt{List: []ast.Stmt{elemStmt}},
 		},
-	}}
+	})
 }
 
 func newAssignStmtMap(
+	scope funcScope,
+	opts assignOptions,
 	left ast.Expr,
 	leftType ast.Expr,
 	leftElemType ast.Expr,
 	right ast.Expr,
 	rightElemType ast.Expr,
-	convertFuncName string,
+	convertFunc convertFunc,
+	userElemFunc valueFunc,
+	elemBuiltin builtinFunc,
 	direct bool,
 	convert bool,
+	path errPath,
 ) ast.Stmt {
-	return &ast.BlockStmt{List: []ast.Stmt{
+	elemPath := path.Index("%v", "k")
+
+	leftElem := &ast.IndexExpr{
+		X:     left,
+		Index: &ast.Ident{Name: "k"},
+	}
+
+	var body []ast.Stmt
+	if userElemFunc.Name != "" {
+		// <left>[k] = <userElemFunc>(v)
+		body = []ast.Stmt{
+			newAssignStmtUserFunc(scope, leftElem, &ast.Ident{Name: "v"}, userElemFunc, elemPath),
+		}
+	} else {
+		// var x <left-value>
+		// x ??assign?? v
+		// <left>[k] = x
+		body = []ast.Stmt{
+			astDeclare(varNameElemPlaceholder, leftElemType),
+			newAssignStmt(
+				scope,
+				opts,
+				&ast.Ident{Name: varNameElemPlaceholder},
+				leftElemType,
+				&ast.Ident{Name: "v"},
+				rightElemType,
+				convertFunc,
+				elemBuiltin,
+				direct,
+				convert,
+				elemPath,
+			),
+			newAssignStmtStructsAndPointers(
+				assignOptions{}, // y was already copied
+				leftElem,
+				leftElemType,
+				&ast.Ident{Name: varNameElemPlaceholder},
+				leftElemType,
+			),
+		}
+	}
+
+	return newAllocateCollection(opts, left, right, []ast.Stmt{
 		// <left> = make(<leftType>, len(<right>))
 		&ast.AssignStmt{
 			Tok: token.ASSIGN,
@@ -238,68 +677,82 @@ func newAssignStmtMap(
 			},
 		},
 		// for k, v := range <right> {
-		// 	var x <left-value>
-		// 	x ??assign?? v
-		// 	<left>[k] = x
+		// 	<left>[k] ??assign?? v
 		// }
 		&ast.RangeStmt{
 			Key:   &ast.Ident{Name: "k"},
 			Value: &ast.Ident{Name: "v"},
 			Tok:   token.DEFINE,
 			X:     right,
-			Body: &ast.BlockStmt{List: []ast.Stmt{
-				astDeclare(varNameElemPlaceholder, leftElemType),
-				newAssignStmt(
-					&ast.Ident{Name: varNameElemPlaceholder},
-					leftElemType,
-					&ast.Ident{Name: "v"},
-					rightElemType,
-					convertFuncName,
-					direct,
-					convert,
-				),
-				newAssignStmtStructsAndPointers(
-					&ast.IndexExpr{
-						X:     left,
-						Index: &ast.Ident{Name: "k"},
-					},
-					leftElemType,
-					&ast.Ident{Name: varNameElemPlaceholder},
-					leftElemType,
-				),
-			}},
+			Body:  &ast.BlockStmt{List: body},
 		},
-	}}
+	})
+}
+
+// newAllocateCollection wraps the statements which allocate and fill in the
+// left slice or map from the right one. Unless nil collections are allocated,
+// a nil right is assigned as nil instead of an empty slice or map.
+func newAllocateCollection(opts assignOptions, left, right ast.Expr, stmts []ast.Stmt) ast.Stmt {
+	if opts.AllocateNil {
+		return &ast.BlockStmt{List: stmts}
+	}
+
+	// if <right> != nil {
+	// 	<stmts>
+	// } else {
+	// 	<left> = nil
+	// }
+	return &ast.IfStmt{
+		Cond: astIsNotNil(right),
+		Body: &ast.BlockStmt{List: stmts},
+		Else: &ast.BlockStmt{List: []ast.Stmt{
+			astAssign(left, &ast.Ident{Name: "nil"}),
+		}},
+	}
 }
 
 func newAssignStmt(
+	scope funcScope,
+	opts assignOptions,
 	left ast.Expr,
 	leftType ast.Expr,
 	right ast.Expr,
 	rightType ast.Expr,
-	convertFuncName string,
+	convertFunc convertFunc,
+	builtin builtinFunc,
 	direct bool,
 	convert bool,
+	path errPath,
 ) ast.Stmt {
 	if direct && convert {
 		panic("direct and convert cannot both be set")
 	}
+	if builtin.Expr != "" {
+		return newAssignStmtBuiltin(scope, opts, left, leftType, right, builtin, path)
+	}
+	if convert && opts.CheckConvert {
+		return newAssignStmtCheckedConvert(scope, opts, left, leftType, right, rightType, path)
+	}
 	if convert {
 		right = &ast.CallExpr{
 			Fun:  leftType,
 			Args: []ast.Expr{right},
 		}
 	}
-	if convertFuncName != "" && !direct && !convert {
+	if convertFunc.Name != "" && !direct && !convert {
 		return newAssignStmtConvertible(
+			scope,
+			opts,
 			left,
 			leftType,
 			right,
 			rightType,
-			convertFuncName,
+			convertFunc,
+			path,
 		)
 	}
 	return newAssignStmtStructsAndPointers(
+		opts,
 		left,
 		leftType,
 		right,
@@ -308,23 +761,302 @@ func newAssignStmt(
 }
 
 func newAssignStmtUserFunc(
+	scope funcScope,
 	left ast.Expr,
 	right ast.Expr,
-	userFuncName string,
+	userFunc valueFunc,
+	path errPath,
 ) ast.Stmt {
 	// No special handling for pointers here if someone used the mog
-	// annotations themselves. The assumption is the user knows what
-	// they're doing.
+	// annotations themselves. The signature of the function is checked by
+	// checkUserFuncSigs, so it takes the value as it is.
+	args := []ast.Expr{right}
+	if userFunc.Ctx {
+		args = append([]ast.Expr{&ast.Ident{Name: varNameCtx}}, args...)
+	}
+	var fun ast.Expr = &ast.Ident{Name: userFunc.Name}
+	if userFunc.Pkg != "" && userFunc.Pkg != scope.imports.local {
+		scope.imports.Add("", userFunc.Pkg)
+		fun = &ast.SelectorExpr{
+			X:   &ast.Ident{Name: scope.imports.AliasFor(userFunc.Pkg)},
+			Sel: &ast.Ident{Name: userFunc.Name},
+		}
+	}
+	call := &ast.CallExpr{
+		Fun:  fun,
+		Args: args,
+	}
+	if userFunc.Method {
+		// <right>.<method>()
+		call = &ast.CallExpr{
+			Fun: &ast.SelectorExpr{X: right, Sel: &ast.Ident{Name: userFunc.Name}},
+		}
+	}
+	result := &ast.Ident{Name: varNamePlaceholder}
+	return newAssignStmtCall(scope, left, call, userFunc.Errors, "", result, path)
+}
+
+// newAssignStmtBuiltin assigns the right value to left using a builtin
+// conversion.
+func newAssignStmtBuiltin(
+	scope funcScope,
+	opts assignOptions,
+	left ast.Expr,
+	leftType ast.Expr,
+	right ast.Expr,
+	builtin builtinFunc,
+	path errPath,
+) ast.Stmt {
+	var stmt ast.Stmt
+	if builtin.Decode {
+		stmt = newAssignStmtDecode(scope, opts, left, leftType, builtin.Call(scope.imports, right), path)
+	} else {
+		call := builtin.Call(scope.imports, right)
+		var result ast.Expr = &ast.Ident{Name: varNamePlaceholder}
+		if builtin.Errors {
+			result = builtin.ResultExpr(scope.imports, result)
+		} else {
+			call = builtin.ResultExpr(scope.imports, call)
+		}
+		stmt = newAssignStmtCall(scope, left, call, builtin.Errors, opts.OnError, result, path)
+	}
+	cond := builtin.CondExpr(scope.imports, right)
+	if cond == nil {
+		return stmt
+	}
+	body := []ast.Stmt{stmt}
+	if block, ok := stmt.(*ast.BlockStmt); ok {
+		body = block.List
+	}
+	return newIfElseNilPolicy(opts, cond, left, leftType, body...)
+}
 
-	// <left> = <funcName>(<right>)
-	return astAssign(left, &ast.CallExpr{
-		Fun:  &ast.Ident{Name: userFuncName},
-		Args: []ast.Expr{right},
+// newAssignStmtDecode returns the statement which decodes a value into a new
+// variable of the left type using call, which refers to the variable as x and
+// returns an error, and then assigns the variable to left.
+func newAssignStmtDecode(
+	scope funcScope,
+	opts assignOptions,
+	left ast.Expr,
+	leftType ast.Expr,
+	call ast.Expr,
+	path errPath,
+) ast.Stmt {
+	var result ast.Expr = &ast.Ident{Name: varNamePlaceholder}
+	if star, ok := leftType.(*ast.StarExpr); ok {
+		leftType = star.X
+		result = newAddressOf(varNamePlaceholder)
+	}
+
+	// _ = <call>
+	var decode ast.Stmt = &ast.AssignStmt{
+		Lhs: []ast.Expr{&ast.Ident{Name: "_"}},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{call},
+	}
+	if opts.OnError != onErrorIgnore {
+		// if err := <call>; err != nil {
+		// 	return fmt.Errorf("<path>: %w", err)
+		// }
+		decode = &ast.IfStmt{
+			Init: &ast.AssignStmt{
+				Lhs: []ast.Expr{&ast.Ident{Name: varNameErr}},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{call},
+			},
+			Cond: astIsNotNil(&ast.Ident{Name: varNameErr}),
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				scope.handleErr(opts.OnError, path),
+			}},
+		}
+	}
+
+	// {
+	// 	var x <leftType>
+	// 	<decode>
+	// 	<left> = x
+	// }
+	return &ast.BlockStmt{List: []ast.Stmt{
+		astDeclare(varNamePlaceholder, leftType),
+		decode,
+		astAssign(left, result),
+	}}
+}
+
+func newAssignStmtCall(
+	scope funcScope,
+	left ast.Expr,
+	call ast.Expr,
+	errors bool,
+	onError onErrorPolicy,
+	result ast.Expr,
+	path errPath,
+) ast.Stmt {
+	if !errors {
+		// <left> = <call>
+		return astAssign(left, call)
+	}
+
+	if onError == onErrorIgnore {
+		var assign ast.Stmt = astAssign(left, result)
+		if _, ok := result.(*ast.StarExpr); ok {
+			// The pointer returned with an error may be nil, in which case
+			// left is not assigned.
+			// if x != nil {
+			// 	<left> = <result>
+			// }
+			assign = &ast.IfStmt{
+				Cond: astIsNotNil(&ast.Ident{Name: varNamePlaceholder}),
+				Body: &ast.BlockStmt{List: []ast.Stmt{assign}},
+			}
+		}
+
+		// {
+		// 	x, _ := <call>
+		// 	<assign>
+		// }
+		return &ast.BlockStmt{List: []ast.Stmt{
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{
+					&ast.Ident{Name: varNamePlaceholder},
+					&ast.Ident{Name: "_"},
+				},
+				Tok: token.DEFINE,
+				Rhs: []ast.Expr{call},
+			},
+			assign,
+		}}
+	}
+
+	// {
+	// 	x, err := <call>
+	// 	if err != nil {
+	// 		return fmt.Errorf("<path>: %w", err)
+	// 	}
+	// 	<left> = <result>
+	// }
+	return &ast.BlockStmt{List: []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{
+				&ast.Ident{Name: varNamePlaceholder},
+				&ast.Ident{Name: varNameErr},
+			},
+			Tok: token.DEFINE,
+			Rhs: []ast.Expr{call},
+		},
+		&ast.IfStmt{
+			Cond: astIsNotNil(&ast.Ident{Name: varNameErr}),
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				scope.handleErr(onError, path),
+			}},
+		},
+		astAssign(left, result),
+	}}
+}
+
+// newAssignStmtCheckedConvert returns the statement which assigns left the
+// type conversion of right, or returns an error if converting the value back
+// does not result in the original value.
+func newAssignStmtCheckedConvert(
+	scope funcScope,
+	opts assignOptions,
+	left ast.Expr,
+	leftType ast.Expr,
+	right ast.Expr,
+	rightType ast.Expr,
+	path errPath,
+) ast.Stmt {
+	x := &ast.Ident{Name: varNamePlaceholder}
+
+	// <rightType>(x) != <right>
+	cond := ast.Expr(&ast.BinaryExpr{
+		X:  &ast.CallExpr{Fun: rightType, Args: []ast.Expr{x}},
+		Op: token.NEQ,
+		Y:  right,
 	})
+	isNegative := func(expr ast.Expr) ast.Expr {
+		// <expr> < 0
+		return &ast.BinaryExpr{
+			X:  expr,
+			Op: token.LSS,
+			Y:  &ast.BasicLit{Kind: token.INT, Value: "0"},
+		}
+	}
+	if opts.CheckNegativeRight {
+		// || <right> < 0
+		cond = &ast.BinaryExpr{X: cond, Op: token.LOR, Y: isNegative(right)}
+	}
+	if opts.CheckNegativeLeft {
+		// || x < 0
+		cond = &ast.BinaryExpr{X: cond, Op: token.LOR, Y: isNegative(x)}
+	}
+
+	// {
+	// 	x := <leftType>(<right>)
+	// 	if <cond> {
+	// 		err := fmt.Errorf("%v can not be converted to <leftType> without loss", <right>)
+	// 		return fmt.Errorf("<path>: %w", err)
+	// 	}
+	// 	<left> = x
+	// }
+	format := "%v can not be converted to " + printTypeExpr(leftType) + " without loss"
+	return &ast.BlockStmt{List: []ast.Stmt{
+		astDefine(varNamePlaceholder, &ast.CallExpr{Fun: leftType, Args: []ast.Expr{right}}),
+		&ast.IfStmt{
+			Cond: cond,
+			Body: &ast.BlockStmt{List: scope.returnNewErr(path, format, right)},
+		},
+		astAssign(left, x),
+	}}
+}
+
+// newAssignStmtEnum returns the switch statement which assigns left the value
+// of the case that right matches. Other values of right are assigned the zero
+// value, or return an error, depending on fallback.
+func newAssignStmtEnum(
+	scope funcScope,
+	left ast.Expr,
+	leftType ast.Expr,
+	right ast.Expr,
+	cases []enumCase,
+	fallback enumFallback,
+	path errPath,
+) ast.Stmt {
+	// switch <right> {
+	// case <match>:
+	// 	<left> = <value>
+	// default:
+	// 	var x <leftType>
+	// 	<left> = x
+	// }
+	body := make([]ast.Stmt, 0, len(cases)+1)
+	for _, c := range cases {
+		body = append(body, &ast.CaseClause{
+			List: []ast.Expr{c.Match},
+			Body: []ast.Stmt{astAssign(left, c.Value)},
+		})
+	}
+
+	var fallbackStmts []ast.Stmt
+	if fallback == enumFallbackError {
+		fallbackStmts = scope.returnNewErr(path, "unknown value %v", right)
+	} else {
+		fallbackStmts = []ast.Stmt{
+			astDeclare(varNamePlaceholder, leftType),
+			astAssign(left, &ast.Ident{Name: varNamePlaceholder}),
+		}
+	}
+	body = append(body, &ast.CaseClause{Body: fallbackStmts})
+
+	return &ast.SwitchStmt{
+		Tag:  right,
+		Body: &ast.BlockStmt{List: body},
+	}
 }
 
 // TODO: do the pointer stuff with go/types instead like everything else now?
 func newAssignStmtStructsAndPointers(
+	opts assignOptions,
 	left ast.Expr,
 	leftType ast.Expr,
 	right ast.Expr,
@@ -345,32 +1077,50 @@ func newAssignStmtStructsAndPointers(
 		// if <right> != nil {
 		//   <left> = *<right>
 		// } else {
-		//   var z <left_type>
-		//   <left> = z
+		//   <nil pointer policy>
 		// }
-		leftPtrType, leftPtr := leftType.(*ast.StarExpr)
-
-		leftRealType := leftType
-		if leftPtr {
-			leftRealType = leftPtrType.X
-		}
-
-		return &ast.IfStmt{
-			Cond: astIsNotNil(right),
-			Body: &ast.BlockStmt{List: []ast.Stmt{
-				astAssign(left, &ast.StarExpr{X: right}),
-			}},
-			Else: &ast.BlockStmt{List: []ast.Stmt{
-				astDeclare(varNamePlaceholder, leftRealType),
-				astAssign(left, &ast.Ident{Name: varNamePlaceholder}),
-			}},
-		}
+		return newIfPointerNotNil(opts, left, leftType, right,
+			astAssign(left, &ast.StarExpr{X: right}),
+		)
 	case leftPtr && !rightPtr:
-		// Value to Pointer
+		// Value to Pointer, copying the value so that left does not point
+		// into right.
 		//
-		// <left> = &<right>
-		return astAssign(left, astAddressOf(right))
+		// {
+		// 	x := <right>
+		// 	<left> = &x
+		// }
+		return &ast.BlockStmt{List: []ast.Stmt{
+			astDefine(varNamePlaceholder, right),
+			astAssign(left, newAddressOf(varNamePlaceholder)),
+		}}
 	case leftPtr && rightPtr:
+		if opts.DeepCopy {
+			// Pointer to Pointer, copying the value pointed to
+			//
+			// if <right> != nil {
+			// 	x := *<right>
+			// 	<left> = &x
+			// } else {
+			// 	<nil pointer policy>
+			// }
+			return newIfPointerNotNil(opts, left, leftType, right,
+				astDefine(varNamePlaceholder, &ast.StarExpr{X: right}),
+				astAssign(left, newAddressOf(varNamePlaceholder)),
+			)
+		}
+		if !opts.NilPointer.IsZero() {
+			// Pointer to Pointer
+			//
+			// if <right> != nil {
+			// 	<left> = <right>
+			// } else {
+			// 	<nil pointer policy>
+			// }
+			return newIfPointerNotNil(opts, left, leftType, right,
+				astAssign(left, right),
+			)
+		}
 		// Pointer to Pointer
 		//
 		// <left> = <right>
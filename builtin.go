@@ -0,0 +1,277 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+	"path"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// builtinConversion converts between a well-known standard library type and
+// a simpler type, like time.Duration and string, without a user supplied
+// function.
+type builtinConversion struct {
+	// ToLeft converts a value of the right type to the left type.
+	ToLeft builtinFunc
+
+	// ToRight converts a value of the left type to the right type.
+	ToRight builtinFunc
+}
+
+// For returns the function used to assign the field in the given direction,
+// where the left side of the assignment is the target.
+func (c *builtinConversion) For(direction Direction) builtinFunc {
+	if c == nil {
+		return builtinFunc{}
+	}
+	if direction == DirFrom {
+		return c.ToRight
+	}
+	return c.ToLeft
+}
+
+// builtinFunc is a single direction of a builtinConversion.
+type builtinFunc struct {
+	// Expr is the Go expression which converts the value, which is written
+	// as _.
+	Expr string
+
+	// Imports are the paths of the packages used by Expr.
+	Imports []string
+
+	// Errors is true when Expr returns the value and an error.
+	Errors bool
+
+	// Result is the Go expression which converts the value returned by Expr
+	// to the left type, written like Expr. The value is assigned as is when
+	// Result is empty.
+	Result string
+
+	// Decode is true when Expr decodes the value into x, a variable of the
+	// left type, and only returns an error.
+	Decode bool
+
+	// Cond is the Go expression which is false when the value is nil or
+	// empty, written like Expr. When Cond is false the value is not converted
+	// and is assigned using the nil pointer policy instead.
+	Cond string
+}
+
+// Call returns the expression which converts value, adding the packages it
+// uses to imports.
+func (f builtinFunc) Call(imports *imports, value ast.Expr) ast.Expr {
+	return f.expand(f.Expr, imports, value)
+}
+
+// CondExpr returns the expression which is false when value should not be
+// converted, or nil if it is always converted.
+func (f builtinFunc) CondExpr(imports *imports, value ast.Expr) ast.Expr {
+	if f.Cond == "" {
+		return nil
+	}
+	return f.expand(f.Cond, imports, value)
+}
+
+// ResultExpr returns the expression which converts the result of the call
+// to the left type.
+func (f builtinFunc) ResultExpr(imports *imports, result ast.Expr) ast.Expr {
+	if f.Result == "" {
+		return result
+	}
+	return f.expand(f.Result, imports, result)
+}
+
+func (f builtinFunc) expand(template string, imports *imports, value ast.Expr) ast.Expr {
+	expr, err := parser.ParseExpr(template)
+	if err != nil {
+		panic(fmt.Sprintf("invalid builtin conversion %q: %v", template, err))
+	}
+
+	pkgs := make(map[string]string, len(f.Imports))
+	for _, pkgPath := range f.Imports {
+		pkgs[path.Base(pkgPath)] = pkgPath
+	}
+	rewriteQualifiers(expr, pkgs, imports)
+
+	return astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		if ident, ok := c.Node().(*ast.Ident); ok && ident.Name == "_" {
+			c.Replace(value)
+		}
+		return true
+	}, nil).(ast.Expr)
+}
+
+// builtinPair is a pair of types with builtin conversions between them. The
+// types are identified by types.TypeString.
+type builtinPair struct {
+	A, B   string
+	AFromB builtinFunc
+	BFromA builtinFunc
+}
+
+// builtinPairs are the supported builtin conversions. time.Duration and
+// integers are converted without a builtin conversion because they are
+// convertible. Conversions between []byte and json.RawMessage copy the bytes,
+// so the converted value does not share memory with the original.
+// net.ParseIP returns nil instead of an error, so an invalid address is
+// converted to a nil net.IP.
+var builtinPairs = []builtinPair{
+	{
+		A:      "[]byte",
+		B:      "string",
+		AFromB: builtinFunc{Expr: "[]byte(_)", Cond: `_ != ""`},
+		BFromA: builtinFunc{Expr: "string(_)"},
+	},
+	{
+		A:      "encoding/json.RawMessage",
+		B:      "[]byte",
+		AFromB: builtinFunc{Expr: "bytes.Clone(_)", Imports: []string{"bytes"}},
+		BFromA: builtinFunc{Expr: "bytes.Clone(_)", Imports: []string{"bytes"}},
+	},
+	{
+		A:      "encoding/json.RawMessage",
+		B:      "string",
+		AFromB: builtinFunc{Expr: "json.RawMessage(_)", Imports: []string{"encoding/json"}, Cond: `_ != ""`},
+		BFromA: builtinFunc{Expr: "string(_)"},
+	},
+	{
+		A:      "time.Duration",
+		B:      "string",
+		AFromB: builtinFunc{Expr: "time.ParseDuration(_)", Imports: []string{"time"}, Errors: true, Cond: `_ != ""`},
+		BFromA: builtinFunc{Expr: "_.String()", Cond: "_ != 0"},
+	},
+	{
+		A:      "time.Time",
+		B:      "string",
+		AFromB: builtinFunc{Expr: "time.Parse(time.RFC3339Nano, _)", Imports: []string{"time"}, Errors: true, Cond: `_ != ""`},
+		BFromA: builtinFunc{Expr: "_.Format(time.RFC3339Nano)", Imports: []string{"time"}, Cond: "!_.IsZero()"},
+	},
+	{
+		A:      "time.Time",
+		B:      "int64",
+		AFromB: builtinFunc{Expr: "time.Unix(_, 0)", Imports: []string{"time"}, Cond: "_ != 0"},
+		BFromA: builtinFunc{Expr: "_.Unix()", Cond: "!_.IsZero()"},
+	},
+	{
+		A:      "net.IP",
+		B:      "string",
+		AFromB: builtinFunc{Expr: "net.ParseIP(_)", Imports: []string{"net"}, Cond: `_ != ""`},
+		BFromA: builtinFunc{Expr: "_.String()", Cond: "_ != nil"},
+	},
+	{
+		A:      "net/url.URL",
+		B:      "string",
+		AFromB: builtinFunc{Expr: "url.Parse(_)", Imports: []string{"net/url"}, Errors: true, Result: "*_", Cond: `_ != ""`},
+		BFromA: builtinFunc{Expr: "_.String()"},
+	},
+	{
+		A:      "*net/url.URL",
+		B:      "string",
+		AFromB: builtinFunc{Expr: "url.Parse(_)", Imports: []string{"net/url"}, Errors: true, Cond: `_ != ""`},
+		BFromA: builtinFunc{Expr: "_.String()", Cond: "_ != nil"},
+	},
+}
+
+// lookupBuiltinConversion returns the builtin conversion between the types,
+// if there is one.
+func lookupBuiltinConversion(leftType, rightType types.Type) (*builtinConversion, bool) {
+	left := builtinTypeString(leftType)
+	right := builtinTypeString(rightType)
+	for _, pair := range builtinPairs {
+		switch {
+		case pair.A == left && pair.B == right:
+			return &builtinConversion{ToLeft: pair.AFromB, ToRight: pair.BFromA}, true
+		case pair.B == left && pair.A == right:
+			return &builtinConversion{ToLeft: pair.BFromA, ToRight: pair.AFromB}, true
+		}
+	}
+	return nil, false
+}
+
+// builtinTypeString returns the string used to identify the type in
+// builtinPairs. A slice of uint8 is identified as []byte.
+func builtinTypeString(t types.Type) string {
+	if types.Identical(t, types.NewSlice(types.Typ[types.Byte])) {
+		return "[]byte"
+	}
+	return types.TypeString(t, nil)
+}
+
+// lookupTextConversion returns the conversion between a type which implements
+// encoding.TextMarshaler and encoding.TextUnmarshaler, and a string or []byte,
+// if the types are not already convertible.
+func lookupTextConversion(leftType, rightType types.Type) (*builtinConversion, bool) {
+	if types.ConvertibleTo(leftType, rightType) && types.ConvertibleTo(rightType, leftType) {
+		return nil, false
+	}
+	if conv, ok := textConversion(leftType, rightType); ok {
+		return &builtinConversion{ToLeft: conv.AFromB, ToRight: conv.BFromA}, true
+	}
+	if conv, ok := textConversion(rightType, leftType); ok {
+		return &builtinConversion{ToLeft: conv.BFromA, ToRight: conv.AFromB}, true
+	}
+	return nil, false
+}
+
+// textConversion returns the conversion between the text type, which
+// implements the text marshaling interfaces, and other, which must be a
+// string or []byte.
+func textConversion(text, other types.Type) (builtinPair, bool) {
+	isString := types.Identical(other, types.Typ[types.String])
+	isBytes := types.Identical(other, types.NewSlice(types.Typ[types.Byte]))
+	if !isString && !isBytes {
+		return builtinPair{}, false
+	}
+
+	// The methods are called on fields and elements, which are addressable,
+	// so the methods of the pointer can be used for values.
+	ptr, isPointer := text.(*types.Pointer)
+	if !isPointer {
+		ptr = types.NewPointer(text)
+	}
+	if !types.Implements(ptr, textMarshaler) || !types.Implements(ptr, textUnmarshaler) {
+		return builtinPair{}, false
+	}
+
+	pair := builtinPair{
+		BFromA: builtinFunc{Expr: "_.MarshalText()", Errors: true},
+		AFromB: builtinFunc{Expr: "x.UnmarshalText(_)", Errors: true, Decode: true, Cond: "len(_) != 0"},
+	}
+	if isString {
+		pair.BFromA.Result = "string(_)"
+		pair.AFromB.Expr = "x.UnmarshalText([]byte(_))"
+		pair.AFromB.Cond = `_ != ""`
+	}
+	if isPointer {
+		pair.BFromA.Cond = "_ != nil"
+	}
+	return pair, true
+}
+
+var (
+	// textMarshaler is encoding.TextMarshaler.
+	textMarshaler = newInterface("MarshalText", nil, []*types.Var{
+		types.NewParam(0, nil, "", types.NewSlice(types.Typ[types.Byte])),
+		types.NewParam(0, nil, "", types.Universe.Lookup("error").Type()),
+	})
+
+	// textUnmarshaler is encoding.TextUnmarshaler.
+	textUnmarshaler = newInterface("UnmarshalText", []*types.Var{
+		types.NewParam(0, nil, "", types.NewSlice(types.Typ[types.Byte])),
+	}, []*types.Var{
+		types.NewParam(0, nil, "", types.Universe.Lookup("error").Type()),
+	})
+)
+
+// newInterface returns an interface with a single method.
+func newInterface(name string, params, results []*types.Var) *types.Interface {
+	sig := types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), types.NewTuple(results...), false)
+	method := types.NewFunc(0, nil, name, sig)
+	return types.NewInterfaceType([]*types.Func{method}, nil).Complete()
+}
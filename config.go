@@ -7,9 +7,12 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
+	"go/constant"
 	"go/format"
+	"go/parser"
 	"go/token"
 	"go/types"
+	"strconv"
 	"strings"
 )
 
@@ -25,21 +28,148 @@ type structConfig struct {
 	Output           string
 	FuncNameFragment string // general namespace for conversion functions
 	IgnoreFields     stringSet
-	FuncFrom         string
-	FuncTo           string
+	FuncFrom         string // reserved, not used yet
+	FuncTo           string // reserved, not used yet
 	Fields           []fieldConfig
+
+	// Errors is true when the generated conversion functions return an error.
+	Errors bool
+
+	// Ctx is the type of the leading ctx argument of the generated conversion
+	// functions. The zero value means there is no ctx argument.
+	Ctx ctxType
+
+	// Constructors is true when functions that allocate and return the
+	// converted value should also be generated.
+	Constructors bool
+
+	// Methods is true when the conversions are generated as methods on the
+	// source struct instead of functions.
+	Methods bool
+
+	// Direction limits the generated conversions to a single direction. The
+	// zero value means both directions are generated.
+	Direction Direction
+
+	// Copy is how slices, maps and pointers are assigned, unless the field
+	// sets its own. The zero value is a shallow copy.
+	Copy copyMode
+
+	// NilCollections is how nil slices and maps are converted, unless the
+	// field sets its own. The zero value preserves nil.
+	NilCollections nilCollections
+
+	// NilPointer is how fields are assigned from nil pointers, unless the
+	// field sets its own.
+	NilPointer nilPointerPolicy
+
+	// MethodTo is the name of the method on the types of the source fields
+	// which converts them to the types of the target fields. The zero value
+	// means methods are not used in the To direction.
+	MethodTo string
+
+	// MethodFrom is the name of the method on the types of the target fields
+	// which converts them to the types of the source fields. The zero value
+	// means methods are not used in the From direction.
+	MethodFrom string
+
+	// Funcs are the functions of the source and helper packages which are
+	// used to convert fields which are not otherwise assignable.
+	Funcs convertFuncs
+}
+
+// AssignOptions returns the options used to assign the field.
+func (c *structConfig) AssignOptions(field fieldConfig) assignOptions {
+	mode := c.Copy
+	if field.Copy != "" {
+		mode = field.Copy
+	}
+	nils := c.NilCollections
+	if field.NilCollections != "" {
+		nils = field.NilCollections
+	}
+	nilPointer := c.NilPointer
+	if field.NilPointer.Mode != "" {
+		nilPointer = field.NilPointer
+	}
+	return assignOptions{
+		DeepCopy:    mode == copyDeep,
+		AllocateNil: nils == nilCollectionsAllocate,
+		NilPointer:  nilPointer,
+		OnError:     field.OnError,
+	}
+}
+
+// Directions returns the directions that conversions are generated for.
+func (c *structConfig) Directions() []Direction {
+	if c.Direction == "" {
+		return []Direction{DirTo, DirFrom}
+	}
+	return []Direction{c.Direction}
+}
+
+// FieldDirections returns the directions that the field is assigned in.
+func (c *structConfig) FieldDirections(field fieldConfig) []Direction {
+	if field.Direction == "" {
+		return c.Directions()
+	}
+	var result []Direction
+	for _, dir := range c.Directions() {
+		if dir == field.Direction {
+			result = append(result, dir)
+		}
+	}
+	return result
+}
+
+// ConvertFunc returns the generated function which converts this struct in
+// the given direction.
+func (c *structConfig) ConvertFunc(direction Direction) convertFunc {
+	return convertFunc{
+		Name:      c.ConvertFuncName(direction),
+		Errors:    c.Errors,
+		Ctx:       c.Ctx,
+		Method:    c.Methods,
+		Direction: direction,
+		Skipped:   c.Direction != "" && c.Direction != direction,
+	}
 }
 
 func (c *structConfig) ConvertFuncName(direction Direction) string {
 	if c.FuncNameFragment == "" {
 		panic("FuncNameFragment is required")
 	}
+	if c.Methods {
+		return direction.String() + c.FuncNameFragment
+	}
 	if direction == DirTo {
 		return c.Source + "To" + c.FuncNameFragment
 	}
 	return c.Source + "From" + c.FuncNameFragment
 }
 
+// ConvertMethodName returns the name of the method on the type of a field
+// which converts it in the given direction, or an empty string if there is
+// none.
+func (c *structConfig) ConvertMethodName(direction Direction) string {
+	if direction == DirFrom {
+		return c.MethodFrom
+	}
+	return c.MethodTo
+}
+
+// ConstructorFuncName returns the name of the function which allocates and
+// returns the converted value in the given direction.
+func (c *structConfig) ConstructorFuncName(direction Direction) string {
+	if c.FuncNameFragment == "" {
+		panic("FuncNameFragment is required")
+	}
+	if direction == DirTo {
+		return "New" + c.FuncNameFragment + "From" + c.Source
+	}
+	return "New" + c.Source + "From" + c.FuncNameFragment
+}
+
 type stringSet map[string]struct{}
 
 func newStringSetFromSlice(s []string) stringSet {
@@ -67,6 +197,76 @@ func newTarget(v string) target {
 	return target{Package: v[:i], Struct: v[i+1:]}
 }
 
+// ctxType is the type of the ctx argument passed through the conversion
+// functions. Package is empty for types declared in the source package.
+type ctxType struct {
+	Pointer bool
+	Package string
+	Name    string
+}
+
+func newCtxType(v string) ctxType {
+	var c ctxType
+	if strings.HasPrefix(v, "*") {
+		c.Pointer = true
+		v = v[1:]
+	}
+	t := newTarget(v)
+	c.Package, c.Name = t.Package, t.Struct
+	return c
+}
+
+func (c ctxType) String() string {
+	s := c.Name
+	if c.Package != "" {
+		s = c.Package + "." + s
+	}
+	if c.Pointer {
+		s = "*" + s
+	}
+	return s
+}
+
+// IsZero returns true if there is no ctx argument.
+func (c ctxType) IsZero() bool {
+	return c.Name == ""
+}
+
+// Matches returns true if a ctx argument can be passed as a value of type t,
+// which is either the ctx type or an empty interface. The ctx type is written
+// without a package when it is declared in pkg, the package of the function
+// which takes the argument.
+func (c ctxType) Matches(t types.Type, pkg *types.Package) bool {
+	if iface, ok := t.Underlying().(*types.Interface); ok && iface.Empty() {
+		return true
+	}
+	local := func(other *types.Package) string {
+		if other.Path() == pkg.Path() {
+			return ""
+		}
+		return other.Path()
+	}
+	s := c.String()
+	return types.TypeString(t, nil) == s || types.TypeString(t, local) == s
+}
+
+// Expr returns the type expression for the ctx argument, adding the package
+// to imports if necessary.
+func (c ctxType) Expr(imports *imports) ast.Expr {
+	var expr ast.Expr = &ast.Ident{Name: c.Name}
+	if c.Package != "" {
+		imports.Add("", c.Package)
+		expr = &ast.SelectorExpr{
+			X:   &ast.Ident{Name: imports.AliasFor(c.Package)},
+			Sel: &ast.Ident{Name: c.Name},
+		}
+	}
+	if c.Pointer {
+		expr = &ast.StarExpr{X: expr}
+	}
+	return expr
+}
+
 type fieldConfig struct {
 	SourceName string
 	SourceExpr ast.Expr // This is the type of the field in the source.
@@ -75,8 +275,127 @@ type fieldConfig struct {
 	FuncFrom   string
 	FuncTo     string
 
-	ConvertFuncFrom string
-	ConvertFuncTo   string
+	// ElemFuncFrom and ElemFuncTo are like FuncFrom and FuncTo, but are
+	// applied to each element of a slice, or each value of a map.
+	ElemFuncFrom string
+	ElemFuncTo   string
+
+	// Direction limits the assignment of the field to the generated
+	// conversion for a single direction. The zero value means both.
+	Direction Direction
+
+	// Copy overrides the copy mode of the struct for this field.
+	Copy copyMode
+
+	// NilCollections overrides how the struct converts nil slices and maps
+	// for this field.
+	NilCollections nilCollections
+
+	// NilPointer overrides how the struct assigns fields from nil pointers
+	// for this field.
+	NilPointer nilPointerPolicy
+
+	// Default is a Go expression assigned to the target in place of the
+	// source, when the source is the zero value or a nil pointer.
+	Default string
+
+	// DefaultType is the type of Default, as it is type checked in the
+	// source file of the struct.
+	DefaultType types.Type
+
+	// DefaultValue is the value of Default when it is a constant, which is
+	// used to check that an untyped constant can be represented by the type
+	// of the target.
+	DefaultValue constant.Value
+
+	// DefaultImports are the paths of the packages referred to by Default,
+	// keyed by the qualifier used in Default.
+	DefaultImports map[string]string
+
+	// Enum is how the constants of the source and target enum types are
+	// paired. The zero value means the field is not converted as an enum.
+	Enum enumMode
+
+	// Lossy is how numeric type conversions which may lose data are
+	// assigned. The zero value means they are not allowed.
+	Lossy lossyMode
+
+	// OnError is how errors from builtin conversions of the field are
+	// handled. The zero value returns them, which requires the struct to
+	// return errors.
+	OnError onErrorPolicy
+
+	// EnumMap are the names of the target constants keyed by the names of
+	// the source constants they are paired with, in place of matching their
+	// names. It is set per field, not on the struct, because every name must
+	// be a constant of the field's types, so that a misspelled or removed
+	// constant is reported instead of silently ignored for the other fields.
+	EnumMap map[string]string
+
+	// EnumFallback is how values which are not one of the constants are
+	// converted. The zero value assigns the zero value.
+	EnumFallback enumFallback
+
+	// AutoFuncTo and AutoFuncFrom are the conversion methods, or the
+	// functions found by signature, which convert the field, or its elements
+	// when AutoElemFuncs is true. They are used like user supplied functions.
+	AutoFuncTo    valueFunc
+	AutoFuncFrom  valueFunc
+	AutoElemFuncs bool
+
+	// UserFuncObjs are the functions, or the types for type conversions, that
+	// the names of the user supplied functions refer to, keyed by name.
+	UserFuncObjs map[string]types.Object
+
+	// Pos is the position of the field in the source package, which is added
+	// to errors when it is valid.
+	Pos token.Position
+
+	ConvertFuncFrom convertFunc
+	ConvertFuncTo   convertFunc
+}
+
+// convertFunc is a generated function that converts between two structs. The
+// function takes 2 pointers and fills in the second from the first.
+type convertFunc struct {
+	Name string
+
+	// Errors is true when the function returns an error.
+	Errors bool
+
+	// Ctx is the type of the leading ctx argument of the function, if any.
+	Ctx ctxType
+
+	// Method is true when the function is a method on the source struct. In
+	// the To direction the method allocates and returns the target, and in the
+	// From direction it fills in the receiver.
+	Method    bool
+	Direction Direction
+
+	// Skipped is true when the function is not generated because the struct
+	// is only converted in the other direction.
+	Skipped bool
+}
+
+// valueFunc is a user supplied function that takes a single value and returns
+// the converted value.
+type valueFunc struct {
+	Name string
+
+	// Errors is true when the function returns an error as a second result.
+	Errors bool
+
+	// Ctx is true when the function takes a ctx as the first argument.
+	Ctx bool
+
+	// Method is true when Name is a method on the value, which is called
+	// without arguments.
+	Method bool
+
+	// Pkg is the import path of the package which declares the function, when
+	// it was found by signature. The function is qualified by the package
+	// when it is not the source package.
+	Pkg string
 }
 
 type Direction string
@@ -88,6 +407,77 @@ const (
 	DirTo   Direction = "To"
 )
 
+// copyMode is how values which would otherwise be shared between the source
+// and the target are assigned.
+type copyMode string
+
+const (
+	// copyShallow assigns slices, maps and pointers directly, so the source
+	// and target share the backing array, map or value.
+	copyShallow copyMode = "shallow"
+
+	// copyDeep allocates new slices and maps, and copies the values that
+	// pointers point to.
+	copyDeep copyMode = "deep"
+)
+
+// nilCollections is how a nil slice or map is converted.
+type nilCollections string
+
+const (
+	// nilCollectionsPreserve converts a nil slice or map to nil, and an empty
+	// one to an empty one.
+	nilCollectionsPreserve nilCollections = "preserve"
+
+	// nilCollectionsAllocate converts a nil slice or map to an empty one.
+	nilCollectionsAllocate nilCollections = "allocate"
+)
+
+// onErrorPolicy is how an error from a builtin conversion is handled, when it
+// is not returned from the generated function.
+type onErrorPolicy string
+
+const (
+	// onErrorPanic panics with the error.
+	onErrorPanic onErrorPolicy = "panic"
+
+	// onErrorIgnore ignores the error, and assigns the value returned with it.
+	onErrorIgnore onErrorPolicy = "ignore"
+)
+
+// nilPointerPolicy is how a value is assigned when the pointer it would be
+// assigned from is nil. Func is the name of the function which returns the
+// value to assign when Mode is nilPointerFunc, and Default is the value to
+// assign when Mode is nilPointerDefault.
+type nilPointerPolicy struct {
+	Mode    string
+	Func    string
+	Default ast.Expr
+}
+
+const (
+	// nilPointerZero assigns the zero value, or nil when the value is also a
+	// pointer.
+	nilPointerZero = "zero"
+
+	// nilPointerKeep leaves the value unchanged.
+	nilPointerKeep = "keep"
+
+	// nilPointerFunc assigns the result of calling a function that takes no
+	// arguments.
+	nilPointerFunc = "func"
+
+	// nilPointerDefault assigns the Default expression. It is set by the
+	// default field annotation instead of nil-pointer.
+	nilPointerDefault = "default"
+)
+
+// IsZero returns true if the zero value is assigned for nil pointers, which is
+// the default.
+func (p nilPointerPolicy) IsZero() bool {
+	return p.Mode == "" || p.Mode == nilPointerZero
+}
+
 func (c fieldConfig) UserFuncName(direction Direction) string {
 	if direction == DirFrom {
 		return c.FuncFrom
@@ -95,11 +485,97 @@ func (c fieldConfig) UserFuncName(direction Direction) string {
 	return c.FuncTo
 }
 
-// ConvertFuncName returns the name of a function that takes 2 pointers and
-// returns nothing.
-func (c fieldConfig) ConvertFuncName(direction Direction) string {
-	if c.UserFuncName(direction) != "" {
-		return ""
+func (c fieldConfig) UserElemFuncName(direction Direction) string {
+	if direction == DirFrom {
+		return c.ElemFuncFrom
+	}
+	return c.ElemFuncTo
+}
+
+// UserFunc returns the user supplied function for the whole field.
+func (c fieldConfig) UserFunc(direction Direction) valueFunc {
+	if fn := c.autoFunc(direction); fn.Name != "" && !c.AutoElemFuncs {
+		return fn
+	}
+	return c.valueFunc(c.UserFuncName(direction))
+}
+
+// UserElemFunc returns the user supplied function for the elements of the
+// field.
+func (c fieldConfig) UserElemFunc(direction Direction) valueFunc {
+	if fn := c.autoFunc(direction); fn.Name != "" && c.AutoElemFuncs {
+		return fn
+	}
+	return c.valueFunc(c.UserElemFuncName(direction))
+}
+
+// userFuncAnnotation is the name of a user supplied function, and the
+// annotation key which sets it.
+type userFuncAnnotation struct {
+	Key  string
+	Name string
+}
+
+// userFuncAnnotations returns the user supplied functions which are set.
+func (c fieldConfig) userFuncAnnotations() []userFuncAnnotation {
+	var result []userFuncAnnotation
+	for _, a := range []userFuncAnnotation{
+		{Key: "func-to", Name: c.FuncTo},
+		{Key: "func-from", Name: c.FuncFrom},
+		{Key: "elem-func-to", Name: c.ElemFuncTo},
+		{Key: "elem-func-from", Name: c.ElemFuncFrom},
+		{Key: "nil-pointer", Name: c.NilPointer.Func},
+	} {
+		if a.Name != "" {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func (c fieldConfig) autoFunc(direction Direction) valueFunc {
+	if direction == DirFrom {
+		return c.AutoFuncFrom
+	}
+	return c.AutoFuncTo
+}
+
+// valueFunc returns the user supplied function with the name. A function
+// which takes two arguments takes a ctx as the first one, which is checked
+// against the ctx of the struct by checkUserFuncSigs.
+func (c fieldConfig) valueFunc(name string) valueFunc {
+	fn := valueFunc{Name: name}
+	if obj, ok := c.UserFuncObjs[name].(*types.Func); ok {
+		sig := obj.Type().(*types.Signature)
+		fn.Errors = returnsError(sig)
+		fn.Ctx = sig.Params().Len() == 2
+	}
+	return fn
+}
+
+// hasUserFuncs returns true if either of the user supplied functions
+// for the whole field are set.
+func (c fieldConfig) hasUserFuncs() bool {
+	return c.FuncTo != "" || c.FuncFrom != "" || (c.hasAutoFuncs() && !c.AutoElemFuncs)
+}
+
+// hasUserElemFuncs returns true if either of the user supplied functions
+// for the elements of the field are set.
+func (c fieldConfig) hasUserElemFuncs() bool {
+	return c.ElemFuncTo != "" || c.ElemFuncFrom != "" || (c.hasAutoFuncs() && c.AutoElemFuncs)
+}
+
+// hasAutoFuncs returns true if either of the conversion methods, or the
+// functions found by signature, are set.
+func (c fieldConfig) hasAutoFuncs() bool {
+	return c.AutoFuncTo.Name != "" || c.AutoFuncFrom.Name != ""
+}
+
+// ConvertFunc returns the function that takes 2 pointers and converts between
+// them, if there is one.
+func (c fieldConfig) ConvertFunc(direction Direction) convertFunc {
+	if c.UserFunc(direction).Name != "" || c.UserElemFunc(direction).Name != "" {
+		return convertFunc{}
 	}
 	if direction == DirTo {
 		return c.ConvertFuncTo
@@ -130,6 +606,23 @@ func configsFromAnnotations(pkg sourcePkg) (config, error) {
 				return c, fmt.Errorf("from source struct %v: %w", name, err)
 			}
 			f.SourceType = typedField.Var.Type()
+			f.Pos = pkg.position(typedField.Field.Pos())
+			f.UserFuncObjs, err = pkg.userFuncObjs(f.userFuncAnnotations())
+			if err != nil {
+				return c, fieldError(cfg, f, f.SourceName, err)
+			}
+			if f.Default != "" {
+				expr, err := parser.ParseExpr(f.Default)
+				if err != nil {
+					return c, fmt.Errorf("from source struct %v: %w", name, err)
+				}
+				tv, err := pkg.evalExpr(f.Default, typedField.Field.Pos())
+				if err != nil {
+					return c, fieldError(cfg, f, f.SourceName, fmt.Errorf("has an invalid default: %w", err))
+				}
+				f.DefaultType, f.DefaultValue = tv.Type, tv.Value
+				f.DefaultImports = pkg.exprImports(expr, typedField.Field.Pos())
+			}
 			cfg.Fields = append(cfg.Fields, f)
 		}
 
@@ -157,10 +650,14 @@ func parseStructAnnotation(name string, doc []*ast.Comment) (structConfig, error
 	for _, line := range doc[i+1:] {
 		buf.WriteString(strings.TrimLeft(line.Text, "/"))
 	}
-	for _, part := range strings.Fields(buf.String()) {
-		kv := strings.Split(part, "=")
-		if len(kv) != 2 {
-			return c, fmt.Errorf("invalid term '%v' in annotation, expected only one =", part)
+	terms, err := splitAnnotation(buf.String())
+	if err != nil {
+		return c, err
+	}
+	for _, part := range terms {
+		kv, err := splitAnnotationTerm(part)
+		if err != nil {
+			return c, err
 		}
 		value := kv[1]
 		switch kv[0] {
@@ -176,6 +673,59 @@ func parseStructAnnotation(name string, doc []*ast.Comment) (structConfig, error
 			c.FuncFrom = value
 		case "func-to":
 			c.FuncTo = value
+		case "errors":
+			v, err := parseBoolTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Errors = v
+		case "ctx":
+			c.Ctx = newCtxType(value)
+		case "constructors":
+			v, err := parseBoolTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Constructors = v
+		case "methods":
+			v, err := parseBoolTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Methods = v
+		case "direction":
+			v, err := parseDirectionTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Direction = v
+		case "copy":
+			v, err := parseCopyTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Copy = v
+		case "nil-collections":
+			v, err := parseNilCollectionsTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.NilCollections = v
+		case "nil-pointer":
+			v, err := parseNilPointerTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.NilPointer = v
+		case "method-to", "method-from":
+			if !token.IsIdentifier(value) {
+				return c, fmt.Errorf("invalid value for %v in term '%v', expected the name of a method", kv[0], part)
+			}
+			if kv[0] == "method-to" {
+				c.MethodTo = value
+			} else {
+				c.MethodFrom = value
+			}
 		default:
 			return c, fmt.Errorf("invalid annotation key %v in term '%v'", kv[0], part)
 		}
@@ -184,6 +734,105 @@ func parseStructAnnotation(name string, doc []*ast.Comment) (structConfig, error
 	return c, nil
 }
 
+func parseBoolTerm(part, key, value string) (bool, error) {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %v in term '%v': %w", key, part, err)
+	}
+	return v, nil
+}
+
+// parseDirectionTerm parses a direction of to, from, or both. Both is
+// returned as an empty Direction.
+func parseDirectionTerm(part, key, value string) (Direction, error) {
+	switch value {
+	case "to":
+		return DirTo, nil
+	case "from":
+		return DirFrom, nil
+	case "both":
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid value for %v in term '%v', expected one of to, from, both", key, part)
+}
+
+func parseCopyTerm(part, key, value string) (copyMode, error) {
+	switch mode := copyMode(value); mode {
+	case copyShallow, copyDeep:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid value for %v in term '%v', expected one of shallow, deep", key, part)
+}
+
+func parseNilCollectionsTerm(part, key, value string) (nilCollections, error) {
+	switch mode := nilCollections(value); mode {
+	case nilCollectionsPreserve, nilCollectionsAllocate:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid value for %v in term '%v', expected one of preserve, allocate", key, part)
+}
+
+// parseNilPointerTerm parses a nil pointer policy of zero, keep, or
+// func:<name>.
+func parseNilPointerTerm(part, key, value string) (nilPointerPolicy, error) {
+	switch {
+	case value == nilPointerZero || value == nilPointerKeep:
+		return nilPointerPolicy{Mode: value}, nil
+	case strings.HasPrefix(value, nilPointerFunc+":") && len(value) > len(nilPointerFunc)+1:
+		return nilPointerPolicy{Mode: nilPointerFunc, Func: strings.TrimPrefix(value, nilPointerFunc+":")}, nil
+	}
+	return nilPointerPolicy{}, fmt.Errorf("invalid value for %v in term '%v', expected one of zero, keep, func:<name>", key, part)
+}
+
+func parseEnumTerm(part, key, value string) (enumMode, error) {
+	switch mode := enumMode(value); mode {
+	case enumOrder, enumName:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid value for %v in term '%v', expected one of order, name", key, part)
+}
+
+func parseLossyTerm(part, key, value string) (lossyMode, error) {
+	switch mode := lossyMode(value); mode {
+	case lossyAllow, lossyCheck:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid value for %v in term '%v', expected one of allow, check", key, part)
+}
+
+func parseOnErrorTerm(part, key, value string) (onErrorPolicy, error) {
+	switch policy := onErrorPolicy(value); policy {
+	case onErrorPanic, onErrorIgnore:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid value for %v in term '%v', expected one of panic, ignore", key, part)
+}
+
+// parseEnumMapTerm parses a comma separated list of <source>:<target> pairs of
+// constant names.
+func parseEnumMapTerm(part, key, value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		names := strings.Split(pair, ":")
+		if len(names) != 2 || names[0] == "" || names[1] == "" {
+			return nil, fmt.Errorf("invalid value for %v in term '%v', expected <source>:<target> pairs separated by ,", key, part)
+		}
+		if _, ok := result[names[0]]; ok {
+			return nil, fmt.Errorf("invalid value for %v in term '%v', %v is paired more than once", key, part, names[0])
+		}
+		result[names[0]] = names[1]
+	}
+	return result, nil
+}
+
+func parseEnumFallbackTerm(part, key, value string) (enumFallback, error) {
+	switch mode := enumFallback(value); mode {
+	case enumFallbackZero, enumFallbackError:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid value for %v in term '%v', expected one of zero, error", key, part)
+}
+
 func (c structConfig) Validate() error {
 	var errs []error
 	fmsg := "missing value for required annotation %q"
@@ -193,12 +842,35 @@ func (c structConfig) Validate() error {
 	if c.Output == "" {
 		errs = append(errs, fmt.Errorf(fmsg, "output"))
 	}
+	if c.Output != "" {
+		if err := validateOutput(c.Output); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if c.FuncNameFragment == "" {
 		errs = append(errs, fmt.Errorf(fmsg, "name"))
 	}
+	if c.Methods && c.Constructors {
+		errs = append(errs, fmt.Errorf("constructors can not be used with methods, the To method already returns a new value"))
+	}
+	if c.NilPointer.Mode == nilPointerFunc {
+		errs = append(errs, fmt.Errorf("nil-pointer=func:<name> can only be used on fields, the function returns the value for a single field"))
+	}
 	return fmtErrors("invalid annotations", errs)
 }
 
+// validateOutput returns an error if the output is not the name of a Go file
+// in the directory of the source package.
+func validateOutput(output string) error {
+	switch {
+	case strings.ContainsAny(output, `/\`):
+		return fmt.Errorf("output %v must be a file name in the source package directory, not a path", output)
+	case !strings.HasSuffix(output, ".go") || output == ".go":
+		return fmt.Errorf("output %v must be the name of a .go file", output)
+	}
+	return nil
+}
+
 // TODO: syntax of mog annotations should be in readme
 func parseFieldAnnotation(field *ast.Field) (fieldConfig, error) {
 	var c fieldConfig
@@ -216,10 +888,14 @@ func parseFieldAnnotation(field *ast.Field) (fieldConfig, error) {
 		return c, nil
 	}
 
-	for _, part := range strings.Fields(text) {
-		kv := strings.Split(part, "=")
-		if len(kv) != 2 {
-			return c, fmt.Errorf("invalid term '%v' in annotation, expected only one =", part)
+	terms, err := splitAnnotation(text)
+	if err != nil {
+		return c, err
+	}
+	for _, part := range terms {
+		kv, err := splitAnnotationTerm(part)
+		if err != nil {
+			return c, err
 		}
 		value := kv[1]
 		switch kv[0] {
@@ -230,10 +906,85 @@ func parseFieldAnnotation(field *ast.Field) (fieldConfig, error) {
 			c.FuncFrom = value
 		case "func-to":
 			c.FuncTo = value
+		case "elem-func-from":
+			c.ElemFuncFrom = value
+		case "elem-func-to":
+			c.ElemFuncTo = value
+		case "direction":
+			v, err := parseDirectionTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Direction = v
+		case "copy":
+			v, err := parseCopyTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Copy = v
+		case "nil-collections":
+			v, err := parseNilCollectionsTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.NilCollections = v
+		case "nil-pointer":
+			v, err := parseNilPointerTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.NilPointer = v
+		case "default":
+			if _, err := parser.ParseExpr(value); err != nil {
+				return c, fmt.Errorf("invalid value for %v in term '%v', expected a Go expression: %w", kv[0], part, err)
+			}
+			c.Default = value
+		case "enum":
+			v, err := parseEnumTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Enum = v
+		case "enum-fallback":
+			v, err := parseEnumFallbackTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.EnumFallback = v
+		case "lossy":
+			v, err := parseLossyTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.Lossy = v
+		case "on-error":
+			v, err := parseOnErrorTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.OnError = v
+		case "enum-map":
+			v, err := parseEnumMapTerm(part, kv[0], value)
+			if err != nil {
+				return c, err
+			}
+			c.EnumMap = v
 		default:
 			return c, fmt.Errorf("invalid annotation key %v in term '%v'", kv[0], part)
 		}
 	}
+	if c.hasUserFuncs() && c.hasUserElemFuncs() {
+		return c, fmt.Errorf("field %v can not use both func-to/func-from and elem-func-to/elem-func-from", c.SourceName)
+	}
+	if c.Enum != "" && (c.hasUserFuncs() || c.hasUserElemFuncs()) {
+		return c, fmt.Errorf("field %v can not use both enum and user functions", c.SourceName)
+	}
+	if c.EnumFallback != "" && c.Enum == "" {
+		return c, fmt.Errorf("field %v can not use enum-fallback without enum", c.SourceName)
+	}
+	if c.EnumMap != nil && c.Enum != enumName {
+		return c, fmt.Errorf("field %v can only use enum-map with enum=name", c.SourceName)
+	}
 	return c, nil
 }
 
@@ -270,6 +1021,89 @@ func getFieldAnnotationLine(doc *ast.CommentGroup) string {
 	return ""
 }
 
+// splitAnnotation splits the text of an annotation into its terms, which are
+// separated by spaces. Spaces in brackets or string literals do not separate
+// terms, so an expression like default=(time.Second * 30) is a single term.
+func splitAnnotation(text string) ([]string, error) {
+	seps, err := annotationSeparators(text, func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation '%v': %w", strings.TrimSpace(text), err)
+	}
+	var terms []string
+	start := 0
+	for _, i := range append(seps, len(text)) {
+		if i > start {
+			terms = append(terms, text[start:i])
+		}
+		start = i + 1
+	}
+	return terms, nil
+}
+
+// splitAnnotationTerm splits the term into its key and value, at the only =
+// which is not in brackets or a string literal.
+func splitAnnotationTerm(term string) ([]string, error) {
+	seps, err := annotationSeparators(term, func(c byte) bool { return c == '=' })
+	if err != nil || len(seps) != 1 {
+		return nil, fmt.Errorf("invalid term '%v' in annotation, expected only one =", term)
+	}
+	return []string{term[:seps[0]], term[seps[0]+1:]}, nil
+}
+
+// annotationSeparators returns the indexes of the bytes in text for which
+// isSep returns true, and which are not in brackets or string literals.
+// Returns an error if a bracket or string literal is not closed.
+func annotationSeparators(text string, isSep func(byte) bool) ([]int, error) {
+	var seps []int
+	var closers []byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; c {
+		case '"', '\'', '`':
+			end := closingQuote(text, i)
+			if end < 0 {
+				return nil, fmt.Errorf("%c is not closed", c)
+			}
+			i = end
+		case '(':
+			closers = append(closers, ')')
+		case '[':
+			closers = append(closers, ']')
+		case '{':
+			closers = append(closers, '}')
+		case ')', ']', '}':
+			if len(closers) == 0 || closers[len(closers)-1] != c {
+				return nil, fmt.Errorf("%c is not opened", c)
+			}
+			closers = closers[:len(closers)-1]
+		default:
+			if len(closers) == 0 && isSep(c) {
+				seps = append(seps, i)
+			}
+		}
+	}
+	if len(closers) > 0 {
+		return nil, fmt.Errorf("%c is missing", closers[len(closers)-1])
+	}
+	return seps, nil
+}
+
+// closingQuote returns the index of the quote which closes the string or rune
+// literal that starts at start, or -1 if it is not closed.
+func closingQuote(text string, start int) int {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case text[i] == quote:
+			return i
+		case text[i] == '\\' && quote != '`':
+			i++
+		}
+	}
+	return -1
+}
+
 func fmtErrors(msg string, errs []error) error {
 	switch len(errs) {
 	case 0:
@@ -288,7 +1122,7 @@ func fmtErrors(msg string, errs []error) error {
 }
 
 // TODO: test cases
-func applyAutoConvertFunctions(cfgs []structConfig) []structConfig {
+func applyAutoConvertFunctions(cfgs []structConfig, targets map[string]targetPkg) []structConfig {
 	// Index the structs by name so any struct can refer to conversion
 	// functions for any other struct.
 	byName := make(map[string]structConfig, len(cfgs))
@@ -306,84 +1140,200 @@ func applyAutoConvertFunctions(cfgs []structConfig) []structConfig {
 			}
 
 			// User supplied override function.
-			if f.FuncTo != "" || f.FuncFrom != "" {
+			if f.hasUserFuncs() || f.hasUserElemFuncs() {
 				continue
 			}
 
-			sourceTypeDecode, ok := decodeType(f.SourceType)
-			if !ok {
-				continue
+			if structCfg, ok := autoConvertStruct(byName, imports, f); ok {
+				// Capture this information so we can use it to know how to
+				// call the conversion functions later.
+				f.ConvertFuncFrom = structCfg.ConvertFunc(DirFrom)
+				f.ConvertFuncTo = structCfg.ConvertFunc(DirTo)
+			} else if targetType := targetFieldType(targets, s, f); targetType != nil && f.Enum == "" {
+				// Enums are converted by their constants, even when the
+				// types have conversion methods.
+				f = applyConvertMethods(s, f, targetType)
 			}
 
-			var (
-				ident *ast.Ident
-			)
-			switch x := sourceTypeDecode.(type) {
-			case *types.Basic:
-				ident = &ast.Ident{Name: x.Name()}
-			case *types.Named:
-				// This only works for types in the source package.
-				decodedTypeExpr := typeToExpr(sourceTypeDecode, imports, false)
-				ident, ok = decodedTypeExpr.(*ast.Ident)
-				if !ok {
-					continue
-				}
-			case *types.Slice:
-				elemDecode, ok := decodeType(x.Elem())
-				if !ok {
-					continue
-				}
-				switch xe := elemDecode.(type) {
-				case *types.Basic:
-					ident = &ast.Ident{Name: xe.Name()}
-				case *types.Named:
-					// This only works for types in the source package.
-					elemDecodeTypeExpr := typeToExpr(elemDecode, imports, true)
-					ident, ok = elemDecodeTypeExpr.(*ast.Ident)
-					if !ok {
-						continue
-					}
-				}
-			case *types.Map:
-				elemDecode, ok := decodeType(x.Elem())
-				if !ok {
-					continue
-				}
-				switch xe := elemDecode.(type) {
-				case *types.Basic:
-					ident = &ast.Ident{Name: xe.Name()}
-				case *types.Named:
-					// This only works for types in the source package.
-					elemDecodeTypeExpr := typeToExpr(elemDecode, imports, true)
-					ident, ok = elemDecodeTypeExpr.(*ast.Ident)
-					if !ok {
-						continue
-					}
-				}
-			}
+			s.Fields[fieldIdx] = f
+		}
+		cfgs[structIdx] = s
+	}
+	return cfgs
+}
 
-			if ident == nil {
-				continue
-			}
+// autoConvertStruct returns the config of the source struct which is the type
+// of the field, or the type of its elements, if there is one.
+func autoConvertStruct(byName map[string]structConfig, imports *imports, f fieldConfig) (structConfig, bool) {
+	sourceTypeDecode, ok := decodeType(f.SourceType)
+	if !ok {
+		return structConfig{}, false
+	}
 
-			// Pull up type information for type of this field and attempt
-			// auto-convert.
-			//
-			// Maybe explicitly skip primitives or stuff like strings?
-			structCfg, ok := byName[ident.Name]
+	var (
+		ident *ast.Ident
+	)
+	switch x := sourceTypeDecode.(type) {
+	case *types.Basic:
+		ident = &ast.Ident{Name: x.Name()}
+	case *types.Named:
+		// This only works for types in the source package.
+		decodedTypeExpr := typeToExpr(sourceTypeDecode, imports, false)
+		ident, ok = decodedTypeExpr.(*ast.Ident)
+		if !ok {
+			return structConfig{}, false
+		}
+	case *types.Slice:
+		elemDecode, ok := decodeType(x.Elem())
+		if !ok {
+			return structConfig{}, false
+		}
+		switch xe := elemDecode.(type) {
+		case *types.Basic:
+			ident = &ast.Ident{Name: xe.Name()}
+		case *types.Named:
+			// This only works for types in the source package.
+			elemDecodeTypeExpr := typeToExpr(elemDecode, imports, true)
+			ident, ok = elemDecodeTypeExpr.(*ast.Ident)
 			if !ok {
-				// TODO: log warning that auto convert did not work
-				continue
+				return structConfig{}, false
+			}
+		}
+	case *types.Map:
+		elemDecode, ok := decodeType(x.Elem())
+		if !ok {
+			return structConfig{}, false
+		}
+		switch xe := elemDecode.(type) {
+		case *types.Basic:
+			ident = &ast.Ident{Name: xe.Name()}
+		case *types.Named:
+			// This only works for types in the source package.
+			elemDecodeTypeExpr := typeToExpr(elemDecode, imports, true)
+			ident, ok = elemDecodeTypeExpr.(*ast.Ident)
+			if !ok {
+				return structConfig{}, false
 			}
+		}
+	}
 
-			// Capture this information so we can use it to know how to call
-			// the conversion functions later.
-			f.ConvertFuncFrom = structCfg.ConvertFuncName(DirFrom)
-			f.ConvertFuncTo = structCfg.ConvertFuncName(DirTo)
+	if ident == nil {
+		return structConfig{}, false
+	}
 
-			s.Fields[fieldIdx] = f
+	// Pull up type information for type of this field and attempt
+	// auto-convert.
+	//
+	// Maybe explicitly skip primitives or stuff like strings?
+	structCfg, ok := byName[ident.Name]
+	// TODO: log warning that auto convert did not work
+	return structCfg, ok
+}
+
+// targetFieldType returns the type of the target field that the field is
+// assigned to, or nil if it can not be found.
+func targetFieldType(targets map[string]targetPkg, s structConfig, f fieldConfig) types.Type {
+	name := f.SourceName
+	if f.TargetName != "" {
+		name = f.TargetName
+	}
+	for _, field := range targets[s.Target.Package].Structs[s.Target.Struct].Fields {
+		if field.Name() == name {
+			return field.Type()
 		}
-		cfgs[structIdx] = s
 	}
-	return cfgs
+	return nil
+}
+
+// convertCandidate is a pair of types which may be converted by a conversion
+// method or function. Elem is true when they are the elements of a slice or
+// the values of a map.
+type convertCandidate struct {
+	Source, Target types.Type
+	Elem           bool
+}
+
+// convertCandidates returns the types of the field, followed by the types of
+// their elements when both are slices, or maps with the same key type.
+func convertCandidates(sourceType, targetType types.Type) []convertCandidate {
+	candidates := []convertCandidate{{Source: sourceType, Target: targetType}}
+	switch source := sourceType.Underlying().(type) {
+	case *types.Slice:
+		if target, ok := targetType.Underlying().(*types.Slice); ok {
+			candidates = append(candidates, convertCandidate{source.Elem(), target.Elem(), true})
+		}
+	case *types.Map:
+		target, ok := targetType.Underlying().(*types.Map)
+		if ok && sameType(source.Key(), target.Key()) {
+			candidates = append(candidates, convertCandidate{source.Elem(), target.Elem(), true})
+		}
+	}
+	return candidates
+}
+
+// applyConvertMethods sets the conversion methods of the field, if the types
+// of the field, or the types of its elements, have methods which convert them
+// in every direction the field is assigned in. The methods are used in place
+// of any other conversion of the field, including builtin and text
+// conversions.
+func applyConvertMethods(s structConfig, f fieldConfig, targetType types.Type) fieldConfig {
+	for _, c := range convertCandidates(f.SourceType, targetType) {
+		var to, from valueFunc
+		found := true
+		for _, dir := range s.FieldDirections(f) {
+			var fn valueFunc
+			if dir == DirTo {
+				fn, found = lookupConvertMethod(c.Source, s.ConvertMethodName(dir), c.Target)
+				to = fn
+			} else {
+				fn, found = lookupConvertMethod(c.Target, s.ConvertMethodName(dir), c.Source)
+				from = fn
+			}
+			if !found {
+				break
+			}
+		}
+		if found {
+			f.AutoFuncTo, f.AutoFuncFrom, f.AutoElemFuncs = to, from, c.Elem
+			return f
+		}
+	}
+	return f
+}
+
+// lookupConvertMethod returns the method of the type with the given name
+// which takes no arguments and returns the result type, and optionally an
+// error.
+func lookupConvertMethod(typ types.Type, name string, result types.Type) (valueFunc, bool) {
+	if name == "" {
+		return valueFunc{}, false
+	}
+	// Fields and elements are addressable, so methods on the pointer can be
+	// called on values.
+	obj, _, _ := types.LookupFieldOrMethod(typ, true, nil, name)
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return valueFunc{}, false
+	}
+
+	sig := fn.Type().(*types.Signature)
+	results := sig.Results()
+	switch {
+	case sig.Params().Len() != 0:
+		return valueFunc{}, false
+	case results.Len() == 0 || results.Len() > 2:
+		return valueFunc{}, false
+	case !sameType(results.At(0).Type(), result):
+		return valueFunc{}, false
+	case results.Len() == 2 && !returnsError(sig):
+		return valueFunc{}, false
+	}
+	return valueFunc{Name: name, Errors: results.Len() == 2, Method: true}, true
+}
+
+// sameType returns true if the types are identical. The source and target
+// packages are loaded separately, so named types from the same package are
+// compared by their qualified names.
+func sameType(a, b types.Type) bool {
+	return types.Identical(a, b) || types.TypeString(a, nil) == types.TypeString(b, nil)
 }
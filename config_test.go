@@ -23,6 +23,8 @@ func TestParseStructAnnotation_Full(t *testing.T) {
 // ignore-fields=RaftIndex,HiddenField,TheThirdOne
 // func-from=convNodeToStructs
 // func-to=convStructsToNode
+// errors=true
+// constructors=true
 `
 	cfg, err := parseStructAnnotation("SourceStruct", newCommentList(comment))
 	require.NoError(t, err)
@@ -35,6 +37,8 @@ func TestParseStructAnnotation_Full(t *testing.T) {
 		IgnoreFields:     newStringSetFromSlice([]string{"RaftIndex", "HiddenField", "TheThirdOne"}),
 		FuncFrom:         "convNodeToStructs",
 		FuncTo:           "convStructsToNode",
+		Errors:           true,
+		Constructors:     true,
 	}
 	require.Equal(t, expected, cfg)
 }
@@ -76,6 +80,88 @@ func TestParseStructAnnotation(t *testing.T) {
 				FuncNameFragment: "Other",
 			},
 		},
+		{
+			name: "ctx",
+			comment: `// mog annotation:
+// target=Foo name=Other ctx=*example.com/org/convert.Context`,
+			expected: structConfig{
+				Source:           "SourceStruct",
+				Target:           target{Struct: "Foo"},
+				FuncNameFragment: "Other",
+				Ctx: ctxType{
+					Pointer: true,
+					Package: "example.com/org/convert",
+					Name:    "Context",
+				},
+			},
+		},
+		{
+			name: "methods",
+			comment: `// mog annotation:
+// target=Foo name=Other methods=true`,
+			expected: structConfig{
+				Source:           "SourceStruct",
+				Target:           target{Struct: "Foo"},
+				FuncNameFragment: "Other",
+				Methods:          true,
+			},
+		},
+		{
+			name: "direction",
+			comment: `// mog annotation:
+// target=Foo name=Other direction=from`,
+			expected: structConfig{
+				Source:           "SourceStruct",
+				Target:           target{Struct: "Foo"},
+				FuncNameFragment: "Other",
+				Direction:        DirFrom,
+			},
+		},
+		{
+			name: "method names",
+			comment: `// mog annotation:
+// target=Foo name=Other method-to=ToAPI method-from=FromAPI`,
+			expected: structConfig{
+				Source:           "SourceStruct",
+				Target:           target{Struct: "Foo"},
+				FuncNameFragment: "Other",
+				MethodTo:         "ToAPI",
+				MethodFrom:       "FromAPI",
+			},
+		},
+		{
+			name: "copy",
+			comment: `// mog annotation:
+// target=Foo name=Other copy=deep`,
+			expected: structConfig{
+				Source:           "SourceStruct",
+				Target:           target{Struct: "Foo"},
+				FuncNameFragment: "Other",
+				Copy:             copyDeep,
+			},
+		},
+		{
+			name: "nil-collections",
+			comment: `// mog annotation:
+// target=Foo name=Other nil-collections=allocate`,
+			expected: structConfig{
+				Source:           "SourceStruct",
+				Target:           target{Struct: "Foo"},
+				FuncNameFragment: "Other",
+				NilCollections:   nilCollectionsAllocate,
+			},
+		},
+		{
+			name: "nil-pointer",
+			comment: `// mog annotation:
+// target=Foo name=Other nil-pointer=keep`,
+			expected: structConfig{
+				Source:           "SourceStruct",
+				Target:           target{Struct: "Foo"},
+				FuncNameFragment: "Other",
+				NilPointer:       nilPointerPolicy{Mode: nilPointerKeep},
+			},
+		},
 		{
 			name: "no leading comment",
 			comment: `// mog annotation:
@@ -123,6 +209,36 @@ func TestParseStructAnnotation_Errors(t *testing.T) {
 			comment: "// mog annotation:\n// target",
 			err:     "invalid term 'target' in annotation, expected only one =",
 		},
+		{
+			name:    "invalid errors value",
+			comment: "// mog annotation:\n// errors=sometimes",
+			err:     "invalid value for errors in term 'errors=sometimes'",
+		},
+		{
+			name:    "invalid direction value",
+			comment: "// mog annotation:\n// direction=sideways",
+			err:     "invalid value for direction in term 'direction=sideways', expected one of to, from, both",
+		},
+		{
+			name:    "invalid copy value",
+			comment: "// mog annotation:\n// copy=twice",
+			err:     "invalid value for copy in term 'copy=twice', expected one of shallow, deep",
+		},
+		{
+			name:    "invalid nil-collections value",
+			comment: "// mog annotation:\n// nil-collections=empty",
+			err:     "invalid value for nil-collections in term 'nil-collections=empty', expected one of preserve, allocate",
+		},
+		{
+			name:    "invalid nil-pointer value",
+			comment: "// mog annotation:\n// nil-pointer=func:",
+			err:     "invalid value for nil-pointer in term 'nil-pointer=func:', expected one of zero, keep, func:<name>",
+		},
+		{
+			name:    "invalid method-to value",
+			comment: "// mog annotation:\n// method-to=s.ToCore",
+			err:     "invalid value for method-to in term 'method-to=s.ToCore', expected the name of a method",
+		},
 		{
 			name:    "invalid term, too many =",
 			comment: "// mog annotation:\n// target=Foo=Thing",
@@ -159,6 +275,266 @@ func TestParseFieldAnnotation_Full(t *testing.T) {
 	require.Equal(t, expected, cfg)
 }
 
+func TestParseFieldAnnotation(t *testing.T) {
+	type testCase struct {
+		name     string
+		comment  string
+		expected fieldConfig
+		err      string
+	}
+	fn := func(t *testing.T, tc testCase) {
+		field := &ast.Field{
+			Doc:   &ast.CommentGroup{List: newCommentList(tc.comment)},
+			Names: []*ast.Ident{{Name: "Some"}},
+			Type:  &ast.Ident{Name: "string"},
+		}
+		cfg, err := parseFieldAnnotation(field)
+		if tc.err != "" {
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+			return
+		}
+		require.NoError(t, err)
+		expected := tc.expected
+		expected.SourceName = "Some"
+		expected.SourceExpr = field.Type
+		require.Equal(t, expected, cfg)
+	}
+
+	var testCases = []testCase{
+		{
+			name:     "elem funcs",
+			comment:  "// mog: elem-func-from=int32 elem-func-to=int",
+			expected: fieldConfig{ElemFuncFrom: "int32", ElemFuncTo: "int"},
+		},
+		{
+			name:    "funcs and elem funcs",
+			comment: "// mog: func-to=int elem-func-to=int",
+			err:     "can not use both func-to/func-from and elem-func-to/elem-func-from",
+		},
+		{
+			name:     "direction",
+			comment:  "// mog: direction=from",
+			expected: fieldConfig{Direction: DirFrom},
+		},
+		{
+			name:     "copy",
+			comment:  "// mog: copy=shallow",
+			expected: fieldConfig{Copy: copyShallow},
+		},
+		{
+			name:     "nil-collections",
+			comment:  "// mog: nil-collections=preserve",
+			expected: fieldConfig{NilCollections: nilCollectionsPreserve},
+		},
+		{
+			name:     "nil-pointer",
+			comment:  "// mog: nil-pointer=func:DefaultTimeout",
+			expected: fieldConfig{NilPointer: nilPointerPolicy{Mode: nilPointerFunc, Func: "DefaultTimeout"}},
+		},
+		{
+			name:     "default",
+			comment:  "// mog: default=structs.DefaultPartition",
+			expected: fieldConfig{Default: "structs.DefaultPartition"},
+		},
+		{
+			name:     "default with spaces",
+			comment:  "// mog: default=(time.Second * 30) target=Timeout",
+			expected: fieldConfig{Default: "(time.Second * 30)", TargetName: "Timeout"},
+		},
+		{
+			name:     "default string with spaces",
+			comment:  `// mog: default="a b" target=Name`,
+			expected: fieldConfig{Default: `"a b"`, TargetName: "Name"},
+		},
+		{
+			name:    "default with unclosed parentheses",
+			comment: "// mog: default=(time.Second * 30",
+			err:     "invalid annotation 'default=(time.Second * 30': ) is missing",
+		},
+		{
+			name:    "invalid default",
+			comment: "// mog: default=structs.",
+			err:     "invalid value for default in term 'default=structs.', expected a Go expression",
+		},
+		{
+			name:     "enum",
+			comment:  "// mog: enum=order enum-fallback=error",
+			expected: fieldConfig{Enum: enumOrder, EnumFallback: enumFallbackError},
+		},
+		{
+			name:    "invalid enum",
+			comment: "// mog: enum=value",
+			err:     "invalid value for enum in term 'enum=value', expected one of order, name",
+		},
+		{
+			name:    "invalid enum-fallback",
+			comment: "// mog: enum=order enum-fallback=panic",
+			err:     "invalid value for enum-fallback in term 'enum-fallback=panic', expected one of zero, error",
+		},
+		{
+			name:    "enum-fallback without enum",
+			comment: "// mog: enum-fallback=zero",
+			err:     "field Some can not use enum-fallback without enum",
+		},
+		{
+			name:    "enum with user functions",
+			comment: "// mog: enum=order func-to=KindToCore",
+			err:     "field Some can not use both enum and user functions",
+		},
+		{
+			name:    "enum-map",
+			comment: "// mog: enum=name enum-map=Kind_Default:KindTypical,Kind_Mesh:KindMeshGateway",
+			expected: fieldConfig{
+				Enum:    enumName,
+				EnumMap: map[string]string{"Kind_Default": "KindTypical", "Kind_Mesh": "KindMeshGateway"},
+			},
+		},
+		{
+			name:    "invalid enum-map",
+			comment: "// mog: enum=name enum-map=Kind_Default",
+			err:     "invalid value for enum-map in term 'enum-map=Kind_Default', expected <source>:<target> pairs separated by ,",
+		},
+		{
+			name:    "enum-map with enum=order",
+			comment: "// mog: enum=order enum-map=Kind_Default:KindTypical",
+			err:     "field Some can only use enum-map with enum=name",
+		},
+		{
+			name:     "lossy",
+			comment:  "// mog: lossy=check",
+			expected: fieldConfig{Lossy: lossyCheck},
+		},
+		{
+			name:    "invalid lossy",
+			comment: "// mog: lossy=truncate",
+			err:     "invalid value for lossy in term 'lossy=truncate', expected one of allow, check",
+		},
+		{
+			name:     "on-error",
+			comment:  "// mog: on-error=panic",
+			expected: fieldConfig{OnError: onErrorPanic},
+		},
+		{
+			name:    "invalid on-error",
+			comment: "// mog: on-error=return",
+			err:     "invalid value for on-error in term 'on-error=return', expected one of panic, ignore",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fn(t, tc)
+		})
+	}
+}
+
+func TestStructConfig_FieldDirections(t *testing.T) {
+	field := fieldConfig{Direction: DirFrom}
+	s := structConfig{}
+	require.Equal(t, []Direction{DirFrom}, s.FieldDirections(field))
+	s.Direction = DirTo
+	require.Empty(t, s.FieldDirections(field))
+}
+
+func TestStructConfig_AssignOptions(t *testing.T) {
+	type testCase struct {
+		name     string
+		cfg      structConfig
+		field    fieldConfig
+		expected assignOptions
+	}
+	fn := func(t *testing.T, tc testCase) {
+		require.Equal(t, tc.expected, tc.cfg.AssignOptions(tc.field))
+	}
+
+	var testCases = []testCase{
+		{
+			name:     "copy of the struct",
+			cfg:      structConfig{Copy: copyDeep},
+			expected: assignOptions{DeepCopy: true},
+		},
+		{
+			name:     "copy of the field",
+			cfg:      structConfig{Copy: copyDeep},
+			field:    fieldConfig{Copy: copyShallow},
+			expected: assignOptions{},
+		},
+		{
+			name:     "nil-collections of the struct",
+			cfg:      structConfig{NilCollections: nilCollectionsAllocate},
+			expected: assignOptions{AllocateNil: true},
+		},
+		{
+			name:     "nil-collections of the field",
+			cfg:      structConfig{NilCollections: nilCollectionsAllocate},
+			field:    fieldConfig{NilCollections: nilCollectionsPreserve},
+			expected: assignOptions{},
+		},
+		{
+			name:     "nil-pointer of the struct",
+			cfg:      structConfig{NilPointer: nilPointerPolicy{Mode: nilPointerKeep}},
+			expected: assignOptions{NilPointer: nilPointerPolicy{Mode: nilPointerKeep}},
+		},
+		{
+			name:     "nil-pointer of the field",
+			cfg:      structConfig{NilPointer: nilPointerPolicy{Mode: nilPointerKeep}},
+			field:    fieldConfig{NilPointer: nilPointerPolicy{Mode: nilPointerFunc, Func: "DefaultTimeout"}},
+			expected: assignOptions{NilPointer: nilPointerPolicy{Mode: nilPointerFunc, Func: "DefaultTimeout"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fn(t, tc)
+		})
+	}
+}
+
+func TestStructConfig_Validate_NilPointerFunc(t *testing.T) {
+	c := structConfig{
+		Source:           "Source",
+		Target:           target{Struct: "Target"},
+		Output:           "out.go",
+		FuncNameFragment: "Core",
+		NilPointer:       nilPointerPolicy{Mode: nilPointerFunc, Func: "Default"},
+	}
+	err := c.Validate()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "nil-pointer=func:<name> can only be used on fields")
+}
+
+func TestStructConfig_Validate_Output(t *testing.T) {
+	type testCase struct {
+		output   string
+		expected string
+	}
+	testCases := []testCase{
+		{output: "node_gen.go"},
+		{output: "../node_gen.go", expected: "output ../node_gen.go must be a file name in the source package directory, not a path"},
+		{output: `sub\node_gen.go`, expected: `output sub\node_gen.go must be a file name in the source package directory, not a path`},
+		{output: "node_gen", expected: "output node_gen must be the name of a .go file"},
+		{output: ".go", expected: "output .go must be the name of a .go file"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.output, func(t *testing.T) {
+			c := structConfig{
+				Source:           "Source",
+				Target:           target{Struct: "Target"},
+				Output:           tc.output,
+				FuncNameFragment: "Core",
+			}
+			err := c.Validate()
+			if tc.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.expected)
+		})
+	}
+}
+
 // TODO: no leading comment
 // TODO: extra newlines before annotation
 // TODO: extra lines after annotation
@@ -0,0 +1,261 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// enumMode is how the constants of two enum types are paired.
+type enumMode string
+
+const (
+	// enumOrder pairs the constants in the order they are declared.
+	enumOrder enumMode = "order"
+
+	// enumName pairs the constants with the same normalized name.
+	enumName enumMode = "name"
+)
+
+// enumFallback is how a value which is not one of the declared constants is
+// converted.
+type enumFallback string
+
+const (
+	// enumFallbackZero assigns the zero value.
+	enumFallbackZero enumFallback = "zero"
+
+	// enumFallbackError returns an error from the generated function.
+	enumFallbackError enumFallback = "error"
+)
+
+// enumPair is a constant of the left enum type, and the constant of the right
+// enum type that it is converted to and from.
+type enumPair struct {
+	Left  *types.Const
+	Right *types.Const
+}
+
+// enumCase is a single case of the switch statement which converts an enum.
+// The left side is assigned Value when the right side is equal to Match.
+type enumCase struct {
+	Match ast.Expr
+	Value ast.Expr
+}
+
+// computeEnumPairs pairs the constants declared for the left and right enum
+// types. overrides are the names of the left constants keyed by the names of
+// the right constants they are paired with, in place of the mode. local is
+// the path of the package of the generated code.
+func computeEnumPairs(mode enumMode, leftType, rightType types.Type, overrides map[string]string, local string) ([]enumPair, error) {
+	left, err := enumConstants(leftType, local)
+	if err != nil {
+		return nil, err
+	}
+	right, err := enumConstants(rightType, local)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(overrides) > 0 && mode != enumName {
+		return nil, fmt.Errorf("uses enum-map which can only be used with enum=%v", enumName)
+	}
+
+	switch mode {
+	case enumOrder:
+		if len(left) != len(right) {
+			return nil, fmt.Errorf("uses enum=%v but %v has %d constants and %v has %d",
+				mode, leftType, len(left), rightType, len(right))
+		}
+		pairs := make([]enumPair, len(left))
+		for i := range left {
+			pairs[i] = enumPair{Left: left[i], Right: right[i]}
+		}
+		return pairs, nil
+	case enumName:
+		return pairEnumConstantsByName(leftType, rightType, left, right, overrides)
+	}
+	return nil, fmt.Errorf("uses unsupported enum mode %v", mode)
+}
+
+// pairEnumConstantsByName pairs the constants with the same normalized name,
+// after pairing the overrides. Every constant must be paired.
+func pairEnumConstantsByName(
+	leftType, rightType types.Type,
+	left, right []*types.Const,
+	overrides map[string]string,
+) ([]enumPair, error) {
+	leftByName := enumConstantsByName(left)
+	rightByName := enumConstantsByName(right)
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paired := make(map[*types.Const]bool, len(left)+len(right))
+	pairs := make([]enumPair, 0, len(right))
+	for _, name := range names {
+		r, ok := rightByName[name]
+		if !ok {
+			return nil, fmt.Errorf("uses enum-map with unknown constant %v of %v", name, rightType)
+		}
+		l, ok := leftByName[overrides[name]]
+		if !ok {
+			return nil, fmt.Errorf("uses enum-map with unknown constant %v of %v", overrides[name], leftType)
+		}
+		if paired[l] {
+			return nil, fmt.Errorf("uses enum-map which pairs constant %v of %v more than once", l.Name(), leftType)
+		}
+		paired[l], paired[r] = true, true
+		pairs = append(pairs, enumPair{Left: l, Right: r})
+	}
+
+	leftByNormalized, err := enumConstantsByNormalizedName(left, paired)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range right {
+		if paired[r] {
+			continue
+		}
+		l, ok := leftByNormalized[normalizeEnumName(r)]
+		if !ok {
+			continue
+		}
+		paired[l], paired[r] = true, true
+		pairs = append(pairs, enumPair{Left: l, Right: r})
+	}
+
+	var unmatched []string
+	for _, consts := range [][]*types.Const{right, left} {
+		for _, c := range consts {
+			if !paired[c] {
+				unmatched = append(unmatched, fmt.Sprintf("%v of %v", c.Name(), c.Type()))
+			}
+		}
+	}
+	if len(unmatched) > 0 {
+		return nil, fmt.Errorf("uses enum=%v but constants %v have no match. Pair them using enum-map.",
+			enumName, strings.Join(unmatched, ", "))
+	}
+
+	// Sort the pairs by the declaration of the right constants so that the
+	// cases are in the same order as the source.
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Right.Pos() < pairs[j].Right.Pos()
+	})
+	return pairs, nil
+}
+
+// enumConstantsByNormalizedName returns the constants which are not already
+// paired keyed by their normalized name.
+func enumConstantsByNormalizedName(consts []*types.Const, paired map[*types.Const]bool) (map[string]*types.Const, error) {
+	result := make(map[string]*types.Const, len(consts))
+	for _, c := range consts {
+		if paired[c] {
+			continue
+		}
+		name := normalizeEnumName(c)
+		if prev, ok := result[name]; ok {
+			return nil, fmt.Errorf("uses enum=%v but constants %v and %v of %v have the same normalized name. Pair them using enum-map.",
+				enumName, prev.Name(), c.Name(), c.Type())
+		}
+		result[name] = c
+	}
+	return result, nil
+}
+
+// normalizeEnumName returns the name of the constant without the name of its
+// type as a prefix, in lower case and without underscores. For example
+// ServiceKind_MeshGateway, ServiceKindMeshGateway and
+// SERVICE_KIND_MESH_GATEWAY are all normalized to meshgateway.
+func normalizeEnumName(c *types.Const) string {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.ReplaceAll(s, "_", ""))
+	}
+	name := normalize(c.Name())
+	if named, ok := c.Type().(*types.Named); ok {
+		prefix := normalize(named.Obj().Name())
+		if trimmed := strings.TrimPrefix(name, prefix); trimmed != "" {
+			name = trimmed
+		}
+	}
+	return name
+}
+
+func enumConstantsByName(consts []*types.Const) map[string]*types.Const {
+	result := make(map[string]*types.Const, len(consts))
+	for _, c := range consts {
+		result[c.Name()] = c
+	}
+	return result
+}
+
+// enumConstants returns the constants declared in the package of the named
+// type which have that type, in the order they are declared. Unexported
+// constants can not be referred to from the generated code when they are
+// declared in a package other than local, so they are skipped.
+func enumConstants(typ types.Type, local string) ([]*types.Const, error) {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return nil, fmt.Errorf("uses enum but %v is not a named type", typ)
+	}
+	if _, ok := named.Underlying().(*types.Basic); !ok {
+		return nil, fmt.Errorf("uses enum but %v is not a string or number type", typ)
+	}
+
+	scope := named.Obj().Pkg().Scope()
+	var result []*types.Const
+	for _, name := range scope.Names() {
+		c, ok := scope.Lookup(name).(*types.Const)
+		switch {
+		case !ok || !types.Identical(c.Type(), named):
+			continue
+		case !c.Exported() && c.Pkg().Path() != local:
+			continue
+		}
+		result = append(result, c)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("uses enum but %v has no constants", typ)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Pos() < result[j].Pos()
+	})
+
+	// Constants with the same value would be duplicate cases in the switch.
+	seen := make(map[string]*types.Const, len(result))
+	for _, c := range result {
+		key := c.Val().ExactString()
+		if prev, ok := seen[key]; ok {
+			return nil, fmt.Errorf("uses enum but constants %v and %v of %v have the same value",
+				prev.Name(), c.Name(), typ)
+		}
+		seen[key] = c
+	}
+	return result, nil
+}
+
+// enumCases returns the cases which convert the enum in the given direction,
+// where the left side of the assignment is the target.
+func enumCases(pairs []enumPair, direction Direction, imports *imports) []enumCase {
+	cases := make([]enumCase, 0, len(pairs))
+	for _, pair := range pairs {
+		c := enumCase{
+			Match: qualifiedName(pair.Right, imports),
+			Value: qualifiedName(pair.Left, imports),
+		}
+		if direction == DirFrom {
+			c.Match, c.Value = c.Value, c.Match
+		}
+		cases = append(cases, c)
+	}
+	return cases
+}
@@ -0,0 +1,111 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// convertFuncs are the package level functions which take a single argument
+// and return a single result, keyed by the types of the argument and result.
+// They are used to convert fields which are not otherwise assignable, without
+// a func-to or func-from annotation.
+type convertFuncs map[convertFuncKey][]*types.Func
+
+// convertFuncKey identifies the functions which convert from Param to
+// Result. The source, target and helper packages are loaded separately, so the
+// types are compared by their qualified names.
+type convertFuncKey struct {
+	Param  string
+	Result string
+}
+
+func newConvertFuncKey(param, result types.Type) convertFuncKey {
+	return convertFuncKey{
+		Param:  types.TypeString(param, nil),
+		Result: types.TypeString(result, nil),
+	}
+}
+
+// Add indexes the function if it has the signature of a conversion function.
+func (c convertFuncs) Add(fn *types.Func) {
+	sig, ok := fn.Type().(*types.Signature)
+	switch {
+	case !ok || sig.Recv() != nil:
+		return
+	case sig.TypeParams().Len() != 0 || sig.Variadic():
+		return
+	case sig.Params().Len() != 1 || sig.Results().Len() != 1:
+		return
+	}
+	key := newConvertFuncKey(sig.Params().At(0).Type(), sig.Results().At(0).Type())
+	c[key] = append(c[key], fn)
+}
+
+// Lookup returns the function which converts param to result, or nil if there
+// is none. Returns an error if more than one function converts them.
+func (c convertFuncs) Lookup(param, result types.Type) (*types.Func, error) {
+	fns := c[newConvertFuncKey(param, result)]
+	switch len(fns) {
+	case 0:
+		return nil, nil
+	case 1:
+		return fns[0], nil
+	}
+
+	names := make([]string, 0, len(fns))
+	for _, fn := range fns {
+		names = append(names, fn.Pkg().Path()+"."+fn.Name())
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("can be converted from %v to %v by more than one function: %v. Set func-to or func-from to choose one.",
+		param, result, strings.Join(names, ", "))
+}
+
+// useFieldFuncs returns true if the field should be converted with the
+// functions found by signature, when there are any. They are not used for
+// types which are assignable, or which are converted by element functions,
+// conversion methods, or the functions generated for another struct.
+func useFieldFuncs(field fieldConfig, targetType types.Type) bool {
+	switch {
+	case field.hasUserElemFuncs():
+		return false
+	case field.ConvertFuncTo.Name != "" || field.ConvertFuncFrom.Name != "":
+		return false
+	}
+	return !types.AssignableTo(field.SourceType, targetType) || !types.AssignableTo(targetType, field.SourceType)
+}
+
+// applyFieldFuncs sets the functions found by signature which convert the
+// field, or the elements of a slice or the values of a map, in every direction
+// the field is assigned in. The field is returned unchanged if any direction
+// has no function. Functions for the whole field are used in preference to
+// functions for the elements.
+func applyFieldFuncs(cfg structConfig, field fieldConfig, targetType types.Type) (fieldConfig, error) {
+	for _, c := range convertCandidates(field.SourceType, targetType) {
+		fns := make(map[Direction]valueFunc, 2)
+		for _, dir := range cfg.FieldDirections(field) {
+			param, res := c.Source, c.Target
+			if dir == DirFrom {
+				param, res = res, param
+			}
+			fn, err := cfg.Funcs.Lookup(param, res)
+			if err != nil {
+				return field, err
+			}
+			if fn == nil {
+				break
+			}
+			fns[dir] = valueFunc{Name: fn.Name(), Pkg: fn.Pkg().Path()}
+		}
+		if len(fns) == len(cfg.FieldDirections(field)) {
+			field.AutoFuncTo, field.AutoFuncFrom, field.AutoElemFuncs = fns[DirTo], fns[DirFrom], c.Elem
+			return field, nil
+		}
+	}
+	return field, nil
+}
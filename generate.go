@@ -5,25 +5,48 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/format"
+	"go/parser"
 	"go/printer"
 	"go/token"
+	"go/types"
+	"log"
+	"os"
 	"path"
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/rboyer/safeio"
 )
 
-func generateFiles(cfg config, targets map[string]targetPkg) error {
+// writeOptions are the options for writing the generated files.
+type writeOptions struct {
+	// Force overwrites output files which were not generated by mog.
+	Force bool
+
+	// Check reports the files which would be removed, without writing or
+	// removing any files.
+	Check bool
+
+	// HandlePkgLoadErr handles the errors from loading the source package
+	// with the generated files, other than type errors.
+	HandlePkgLoadErr handlePkgLoadErr
+}
+
+func generateFiles(cfg config, targets map[string]targetPkg, opts writeOptions) error {
 	byOutput := configsByOutput(cfg.Structs)
 
+	files := make([]generatedFile, 0, len(byOutput))
 	for _, group := range byOutput {
 		var decls []ast.Decl
+		var declStructs []structConfig
 		imports := newImports()
+		imports.local = cfg.SourcePkg.PkgPath()
 
 		for _, sourceStruct := range group {
 			t := targets[sourceStruct.Target.Package].Structs[sourceStruct.Target.Struct]
@@ -35,7 +58,12 @@ func generateFiles(cfg config, targets map[string]targetPkg) error {
 			if err != nil {
 				return fmt.Errorf("failed to generate conversion for %v: %w", sourceStruct.Source, err)
 			}
-			decls = append(decls, gen.To, gen.From)
+			for _, decl := range []*ast.FuncDecl{gen.To, gen.From, gen.NewTo, gen.NewFrom} {
+				if decl != nil {
+					decls = append(decls, decl)
+					declStructs = append(declStructs, sourceStruct)
+				}
+			}
 
 			// TODO: generate round trip testcase
 		}
@@ -46,15 +74,279 @@ func generateFiles(cfg config, targets map[string]targetPkg) error {
 
 		// Add all imports as the first declaration
 		// TODO: dedupe imports, handle conflicts
+		imports.RemoveUnused(decls)
 		file.Decls = append([]ast.Decl{imports.Decl()}, decls...)
 
-		if err := astWriteToFile(output, fset, file); err != nil {
-			return fmt.Errorf("failed to write generated code to %v: %w", output, err)
+		out, err := astToBytes(fset, file)
+		if err != nil {
+			return fmt.Errorf("failed to format generated code for %v: %w", output, err)
+		}
+		files = append(files, generatedFile{
+			Path:     output,
+			Contents: append([]byte(generatedHeader), out...),
+			Structs:  declStructs,
+		})
+	}
+
+	if !opts.Force {
+		var errs []error
+		for _, file := range files {
+			if err := checkOverwrite(file.Path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := fmtErrors("refusing to overwrite files", errs); err != nil {
+			return err
+		}
+	}
+
+	orphans, excluded, err := orphanedFiles(cfg.SourcePkg, files)
+	if err != nil {
+		return fmt.Errorf("failed to find orphaned generated files: %w", err)
+	}
+	if len(excluded) > 0 {
+		if !opts.Force {
+			return fmt.Errorf("refusing to remove generated files which are not part of the package "+
+				"with build tags %q: %v. Use -force to remove them.",
+				cfg.SourcePkg.BuildTags, strings.Join(excluded, ", "))
+		}
+		orphans = append(orphans, excluded...)
+	}
+	// A run which finds no annotated structs is more likely caused by a wrong
+	// -source or -tags than by removing every annotation, so the generated
+	// files are only removed when asked for.
+	if len(cfg.Structs) == 0 && len(orphans) > 0 && !opts.Force && !opts.Check {
+		return fmt.Errorf("refusing to remove generated files because no annotated structs were found: %v. "+
+			"Check -source and -tags, or use -force to remove them.", strings.Join(orphans, ", "))
+	}
+
+	// Refuse to write code which does not compile, because it would also
+	// break loading the source package the next time mog is run.
+	if len(files) > 0 || len(orphans) > 0 {
+		if err := checkGeneratedFiles(cfg.SourcePkg, files, orphans, opts.HandlePkgLoadErr); err != nil {
+			return err
+		}
+	}
+
+	statuses := make([]fileStatus, len(files))
+	for i, file := range files {
+		status, err := generatedFileStatus(file)
+		if err != nil {
+			return fmt.Errorf("failed to compare generated code to %v: %w", file.Path, err)
+		}
+		statuses[i] = status
+	}
+
+	if opts.Check {
+		var errs []error
+		for i, file := range files {
+			if statuses[i] == fileUnchanged {
+				log.Printf("%v: %v", file.Path, statuses[i])
+				continue
+			}
+			log.Printf("%v: would be %v", file.Path, statuses[i])
+			errs = append(errs, fmt.Errorf("%v is out of date and would be %v", file.Path, statuses[i]))
+		}
+		for _, orphan := range orphans {
+			log.Printf("%v: would be %v", orphan, fileRemoved)
+			errs = append(errs, fmt.Errorf("%v is orphaned and would be %v", orphan, fileRemoved))
+		}
+		return fmtErrors("generated files are out of date", errs)
+	}
+
+	for i, file := range files {
+		// Files which have not changed are not written, so their modification
+		// time does not change.
+		if statuses[i] != fileUnchanged {
+			if err := writeFile(file.Path, file.Contents); err != nil {
+				return fmt.Errorf("failed to write generated code to %v: %w", file.Path, err)
+			}
 		}
+		log.Printf("%v: %v", file.Path, statuses[i])
+	}
+	for _, orphan := range orphans {
+		if err := os.Remove(orphan); err != nil {
+			return fmt.Errorf("failed to remove orphaned generated file: %w", err)
+		}
+		log.Printf("%v: %v", orphan, fileRemoved)
 	}
 	return nil
 }
 
+// fileStatus is how a generated file changes when it is written.
+type fileStatus string
+
+const (
+	fileCreated   fileStatus = "created"
+	fileUpdated   fileStatus = "updated"
+	fileUnchanged fileStatus = "unchanged"
+	fileRemoved   fileStatus = "removed"
+)
+
+// generatedFileStatus compares the generated file to the file on disk.
+func generatedFileStatus(file generatedFile) (fileStatus, error) {
+	existing, err := os.ReadFile(file.Path)
+	switch {
+	case os.IsNotExist(err):
+		return fileCreated, nil
+	case err != nil:
+		return "", err
+	case bytes.Equal(existing, file.Contents):
+		return fileUnchanged, nil
+	}
+	return fileUpdated, nil
+}
+
+// orphanedFiles returns the files in the directory of the source package which
+// were generated by mog, but are not generated by this run. Files are not
+// orphaned when the source package was loaded with a glob, because the other
+// files may be generated from the files that did not match it.
+//
+// Only the files which are part of the loaded package are returned as orphans.
+// Generated files which were excluded by the build tags may be generated by
+// a run with other tags, so they are returned separately as excluded.
+func orphanedFiles(p sourcePkg, files []generatedFile) (orphans []string, excluded []string, err error) {
+	if p.Glob != "" || p.Path == "" {
+		return nil, nil, nil
+	}
+
+	generated := make(map[string]bool, len(files))
+	for _, file := range files {
+		generated[file.Path] = true
+	}
+	inPackage := make(map[string]bool)
+	if p.pkg != nil {
+		for _, filename := range append(p.pkg.GoFiles, p.pkg.CompiledGoFiles...) {
+			inPackage[filename] = true
+		}
+	}
+
+	entries, err := os.ReadDir(p.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		filename := filepath.Join(p.Path, entry.Name())
+		if entry.IsDir() || filepath.Ext(filename) != ".go" || generated[filename] {
+			continue
+		}
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case !isMogGenerated(contents):
+			continue
+		case inPackage[filename]:
+			orphans = append(orphans, filename)
+		default:
+			excluded = append(excluded, filename)
+		}
+	}
+	return orphans, excluded, nil
+}
+
+// generatedHeader is the comment at the start of every generated file.
+const generatedHeader = "// Code generated by mog. DO NOT EDIT.\n\n"
+
+// isMogGenerated returns true if the contents of the file start with the
+// generatedHeader.
+func isMogGenerated(contents []byte) bool {
+	return bytes.HasPrefix(contents, []byte(strings.TrimSpace(generatedHeader)))
+}
+
+// checkOverwrite returns an error if the file exists and was not generated by
+// mog, so that a mistake in the output annotation does not replace hand
+// written code.
+func checkOverwrite(path string) error {
+	contents, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case !isMogGenerated(contents):
+		return fmt.Errorf("%v was not generated by mog. Change the output annotation, or use -force to overwrite it.", path)
+	}
+	return nil
+}
+
+// generatedFile is the contents of a file before it is written.
+type generatedFile struct {
+	// Path is the absolute path the file is written to.
+	Path string
+
+	// Contents of the file, including the generatedHeader.
+	Contents []byte
+
+	// Structs are the configs of the structs converted by each function
+	// declared in the file, in the order they are declared.
+	Structs []structConfig
+}
+
+// typeErrorFor returns the error for a type error at the position in the
+// generated file, with the struct and field whose conversion caused it when
+// they can be found.
+func typeErrorFor(file generatedFile, pos token.Position, msg string) error {
+	fset := token.NewFileSet()
+	parsed, err := parser.ParseFile(fset, file.Path, file.Contents, 0)
+	if err != nil {
+		return fmt.Errorf("%v: %v", pos, msg)
+	}
+
+	var funcIdx int
+	for _, decl := range parsed.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		idx := funcIdx
+		funcIdx++
+		if !containsLine(fset, funcDecl, pos.Line) || idx >= len(file.Structs) {
+			continue
+		}
+
+		cfg := file.Structs[idx]
+		for _, stmt := range funcDecl.Body.List {
+			if !containsLine(fset, stmt, pos.Line) {
+				continue
+			}
+			if name := targetFieldName(stmt); name != "" {
+				field := sourceFieldMap(cfg.Fields)[name]
+				return fieldError(cfg, field, name, fmt.Errorf("does not compile: %v (%v)", msg, pos))
+			}
+		}
+		return fmt.Errorf("%v: struct %v does not compile: %v", pos, cfg.Source, msg)
+	}
+	return fmt.Errorf("%v: %v", pos, msg)
+}
+
+func containsLine(fset *token.FileSet, node ast.Node, line int) bool {
+	return fset.Position(node.Pos()).Line <= line && line <= fset.Position(node.End()).Line
+}
+
+// targetFieldName returns the name of the first field of the target that the
+// statement refers to. Every statement which assigns a field refers to the
+// field of the target, in either direction.
+func targetFieldName(stmt ast.Stmt) string {
+	var name string
+	ast.Inspect(stmt, func(node ast.Node) bool {
+		if name != "" {
+			return false
+		}
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Name == varNameTarget {
+			name = sel.Sel.Name
+			return false
+		}
+		return true
+	})
+	return name
+}
+
 // configsByOutput sorts and groups the configs by the Output filename. Each
 // group is sorted by name of struct.
 func configsByOutput(cfgs []structConfig) [][]structConfig {
@@ -89,6 +381,9 @@ var (
 	varNameTarget          = "t"
 	varNamePlaceholder     = "x"
 	varNameElemPlaceholder = "y"
+	varNameErr             = "err"
+	varNameCtx             = "ctx"
+	varNameResult          = "r"
 )
 
 func generateConversion(cfg structConfig, t targetStruct, imports *imports) (generated, error) {
@@ -101,8 +396,28 @@ func generateConversion(cfg structConfig, t targetStruct, imports *imports) (gen
 		Sel: &ast.Ident{Name: cfg.Target.Struct},
 	}
 
-	to := generateToFunc(cfg, targetType)
-	from := generateFromFunc(cfg, targetType)
+	var ctxParam *ast.Field
+	if !cfg.Ctx.IsZero() {
+		ctxParam = &ast.Field{
+			Names: []*ast.Ident{{Name: varNameCtx}},
+			Type:  cfg.Ctx.Expr(imports),
+		}
+	}
+
+	decls := make(map[Direction]*ast.FuncDecl, 2)
+	scopes := make(map[Direction]funcScope, 2)
+	for _, dir := range cfg.Directions() {
+		scope := funcScope{Errors: cfg.Errors, imports: imports}
+		if dir == DirTo {
+			decls[dir] = generateToFunc(cfg, targetType, ctxParam)
+			if cfg.Methods {
+				scope.Results = []ast.Expr{&ast.Ident{Name: "nil"}}
+			}
+		} else {
+			decls[dir] = generateFromFunc(cfg, targetType, ctxParam)
+		}
+		scopes[dir] = scope
+	}
 
 	var errs []error
 
@@ -123,6 +438,17 @@ func generateConversion(cfg structConfig, t targetStruct, imports *imports) (gen
 			continue
 		}
 
+		if err := checkFieldFuncs(cfg, sourceField); err != nil {
+			errs = append(errs, fieldError(cfg, sourceField, name, err))
+			continue
+		}
+
+		if err := checkUserFuncSigs(cfg, sourceField, field.Type()); err != nil {
+			errs = append(errs, fieldError(cfg, sourceField, name, err))
+			continue
+		}
+
+		path := newErrPath(name)
 		srcExpr := &ast.SelectorExpr{
 			X:   &ast.Ident{Name: varNameSource},
 			Sel: &ast.Ident{Name: sourceField.SourceName},
@@ -132,155 +458,689 @@ func generateConversion(cfg structConfig, t targetStruct, imports *imports) (gen
 			Sel: &ast.Ident{Name: name},
 		}
 
-		if sourceField.FuncTo != "" || sourceField.FuncFrom != "" {
-			to.Body.List = append(to.Body.List, newAssignStmtUserFunc(
-				targetExpr,
-				srcExpr,
-				sourceField.FuncTo,
-			))
-			from.Body.List = append(from.Body.List, newAssignStmtUserFunc(
-				srcExpr,
-				targetExpr,
-				sourceField.FuncFrom,
-			))
+		var defaultValue, isZero ast.Expr
+		if sourceField.Default != "" {
+			var err error
+			defaultValue, isZero, err = fieldDefault(cfg, sourceField, field.Type(), srcExpr, imports)
+			if err != nil {
+				errs = append(errs, fieldError(cfg, sourceField, name, err))
+				continue
+			}
+		}
+
+		assignUserFuncs := func(userFunc func(Direction) valueFunc) {
+			for _, dir := range cfg.FieldDirections(sourceField) {
+				left, right := ast.Expr(targetExpr), ast.Expr(srcExpr)
+				if dir == DirFrom {
+					left, right = right, left
+				}
+				stmt := newAssignStmtUserFunc(
+					scopes[dir],
+					left,
+					right,
+					userFunc(dir),
+					path,
+				)
+				if dir == DirTo && defaultValue != nil {
+					stmt = newIfZeroDefault(isZero, left, defaultValue, stmt)
+				}
+				decls[dir].Body.List = append(decls[dir].Body.List, stmt)
+			}
+		}
+
+		if sourceField.hasUserFuncs() {
+			assignUserFuncs(sourceField.UserFunc)
 			continue
 		}
 
 		targetTypeExpr := typeToExpr(field.Type(), imports, false)
 		if targetTypeExpr == nil {
-			msg := "struct %v field %v is not a supported target type yet: %T"
-			errs = append(errs, fmt.Errorf(msg, cfg.Source, name, field.Type()))
+			err := fmt.Errorf("is not a supported target type yet: %T", field.Type())
+			errs = append(errs, fieldError(cfg, sourceField, name, err))
+			continue
+		}
+
+		if sourceField.Enum != "" {
+			pairs, err := checkEnum(cfg, sourceField, field.Type(), imports.local)
+			if err != nil {
+				errs = append(errs, fieldError(cfg, sourceField, name, err))
+				continue
+			}
+			for _, dir := range cfg.FieldDirections(sourceField) {
+				left, right := ast.Expr(targetExpr), ast.Expr(srcExpr)
+				leftType := targetTypeExpr
+				if dir == DirFrom {
+					left, right = right, left
+					leftType = sourceField.SourceExpr
+				}
+				stmt := newAssignStmtEnum(
+					scopes[dir],
+					left,
+					leftType,
+					right,
+					enumCases(pairs, dir, imports),
+					sourceField.EnumFallback,
+					path,
+				)
+				if dir == DirTo && defaultValue != nil {
+					stmt = newIfZeroDefault(isZero, left, defaultValue, stmt)
+				}
+				decls[dir].Body.List = append(decls[dir].Body.List, stmt)
+			}
 			continue
 		}
 
+		if useFieldFuncs(sourceField, field.Type()) {
+			// Use the functions which convert between the types, or their
+			// elements, if the source or helper packages have them. They take
+			// precedence over the builtin and text conversions below.
+			var err error
+			sourceField, err = applyFieldFuncs(cfg, sourceField, field.Type())
+			if err != nil {
+				errs = append(errs, fieldError(cfg, sourceField, name, err))
+				continue
+			}
+			if sourceField.hasUserFuncs() {
+				assignUserFuncs(sourceField.UserFunc)
+				continue
+			}
+		}
+
 		assignErrFn := func(err error) {
 			if err == nil {
-				errs = append(errs, fmt.Errorf(
-					"struct %v field %v is not convertible to target",
-					cfg.Source, name,
-				))
+				errs = append(errs, fieldError(cfg, sourceField, name, errors.New("is not convertible to target")))
 			} else {
-				errs = append(errs, fmt.Errorf(
-					"struct %v field %v is not convertible to target: %w",
-					cfg.Source, name, err,
-				))
+				errs = append(errs, fieldError(cfg, sourceField, name, fmt.Errorf("is not convertible to target: %w", err)))
 			}
 		}
 
 		// the assignmentKind is <target> := <source> so target==LHS source==RHS
-		rawKind, ok := computeAssignment(field.Type(), sourceField.SourceType)
+		var rawKind assignmentKind
+		dirs := cfg.FieldDirections(sourceField)
+		if sourceField.hasUserElemFuncs() {
+			rawKind, ok = computeElemFuncAssignment(field.Type(), sourceField.SourceType, dirs)
+		} else {
+			rawKind, ok = computeAssignment(field.Type(), sourceField.SourceType, dirs)
+		}
 		if !ok {
-			assignErrFn(nil)
+			assignErrFn(runeConversionError(field.Type(), sourceField.SourceType))
 			continue
 		}
 
+		if err := checkBuiltin(cfg, sourceField, rawKind); err != nil {
+			errs = append(errs, fieldError(cfg, sourceField, name, err))
+			continue
+		}
+
+		if err := checkLossy(cfg, sourceField, rawKind); err != nil {
+			errs = append(errs, fieldError(cfg, sourceField, name, err))
+			continue
+		}
+
+		if err := checkNilPointerFunc(cfg, sourceField, rawKind); err != nil {
+			errs = append(errs, fieldError(cfg, sourceField, name, err))
+			continue
+		}
+
+		opts := cfg.AssignOptions(sourceField)
+		if opts.DeepCopy {
+			rawKind = deepCopyAssignment(rawKind)
+		}
+
+		target := fieldSide{Expr: targetExpr, Type: targetTypeExpr}
+		source := fieldSide{Expr: srcExpr, Type: sourceField.SourceExpr}
+
 		switch kind := rawKind.(type) {
-		case *singleAssignmentKind:
-			to.Body.List = append(to.Body.List, newAssignStmt(
-				targetExpr,
-				targetTypeExpr,
-				srcExpr,
-				sourceField.SourceExpr,
-				sourceField.ConvertFuncName(DirTo),
-				kind.Direct,
-				kind.Convert,
-			))
-			from.Body.List = append(from.Body.List, newAssignStmt(
-				srcExpr,
-				sourceField.SourceExpr,
-				targetExpr,
-				targetTypeExpr,
-				sourceField.ConvertFuncName(DirFrom),
-				kind.Direct,
-				kind.Convert,
-			))
 		case *sliceAssignmentKind:
-			targetElemTypeElem := typeToExpr(kind.LeftElem, imports, true)
-			if targetElemTypeElem == nil {
+			target.ElemType = typeToExpr(kind.LeftElem, imports, true)
+			if target.ElemType == nil {
 				assignErrFn(fmt.Errorf("unsupported slice element type %T", kind.LeftElem))
 				continue
 			}
 
-			sourceElemTypeElem := typeToExpr(kind.RightElem, imports, true)
-			if sourceElemTypeElem == nil {
+			source.ElemType = typeToExpr(kind.RightElem, imports, true)
+			if source.ElemType == nil {
 				assignErrFn(fmt.Errorf("unsupported slice element type %T", kind.RightElem))
 				continue
 			}
-
-			to.Body.List = append(to.Body.List, newAssignStmtSlice(
-				targetExpr,
-				targetTypeExpr,
-				targetElemTypeElem,
-				srcExpr,
-				sourceElemTypeElem,
-				sourceField.ConvertFuncName(DirTo),
-				kind.ElemDirect,
-				kind.ElemConvert,
-			))
-			from.Body.List = append(from.Body.List, newAssignStmtSlice(
-				srcExpr,
-				sourceField.SourceExpr,
-				sourceElemTypeElem,
-				targetExpr,
-				targetElemTypeElem,
-				sourceField.ConvertFuncName(DirFrom),
-				kind.ElemDirect,
-				kind.ElemConvert,
-			))
 		case *mapAssignmentKind:
-			targetKeyTypeElem := typeToExpr(kind.LeftKey, imports, true)
-			if targetKeyTypeElem == nil {
+			if typeToExpr(kind.LeftKey, imports, true) == nil {
 				assignErrFn(fmt.Errorf("unsupported map key type %T", kind.LeftKey))
 				continue
 			}
 
-			targetElemTypeElem := typeToExpr(kind.LeftElem, imports, true)
-			if targetElemTypeElem == nil {
+			target.ElemType = typeToExpr(kind.LeftElem, imports, true)
+			if target.ElemType == nil {
 				assignErrFn(fmt.Errorf("unsupported map value type %T", kind.LeftElem))
 				continue
 			}
 
-			sourceKeyTypeElem := typeToExpr(kind.RightKey, imports, true)
-			if sourceKeyTypeElem == nil {
+			if typeToExpr(kind.RightKey, imports, true) == nil {
 				assignErrFn(fmt.Errorf("unsupported map key type %T", kind.RightKey))
 				continue
 			}
 
-			sourceElemTypeElem := typeToExpr(kind.RightElem, imports, true)
-			if sourceElemTypeElem == nil {
+			source.ElemType = typeToExpr(kind.RightElem, imports, true)
+			if source.ElemType == nil {
 				assignErrFn(fmt.Errorf("unsupported map value type %T", kind.RightElem))
 				continue
 			}
+		}
 
-			to.Body.List = append(to.Body.List, newAssignStmtMap(
-				targetExpr,
-				targetTypeExpr,
-				targetElemTypeElem,
-				srcExpr,
-				sourceElemTypeElem,
-				sourceField.ConvertFuncName(DirTo),
-				kind.ElemDirect,
-				kind.ElemConvert,
-			))
-			from.Body.List = append(from.Body.List, newAssignStmtMap(
-				srcExpr,
-				sourceField.SourceExpr,
-				sourceElemTypeElem,
-				targetExpr,
-				targetElemTypeElem,
-				sourceField.ConvertFuncName(DirFrom),
-				kind.ElemDirect,
-				kind.ElemConvert,
-			))
-		}
-	}
-
-	g.To = to
-	g.From = from
+		for _, dir := range cfg.FieldDirections(sourceField) {
+			dirOpts := opts
+			_, single := rawKind.(*singleAssignmentKind)
+			useNilPointer := single && isPointer(sourceField.SourceType)
+			if dir == DirTo && defaultValue != nil && useNilPointer {
+				// The default is assigned in place of the zero value for nil
+				// pointers.
+				dirOpts.NilPointer = nilPointerPolicy{Mode: nilPointerDefault, Default: defaultValue}
+			}
+			if conv, ok := numericConversionFor(rawKind, dir); ok && conv.Lossy() && sourceField.Lossy == lossyCheck {
+				dirOpts.CheckConvert = true
+				dirOpts.CheckNegativeRight = conv.SignChange() && isUnsigned(conv.To)
+				dirOpts.CheckNegativeLeft = conv.SignChange() && isUnsigned(conv.From)
+			}
+
+			stmt := newFieldAssignStmt(
+				scopes[dir],
+				dirOpts,
+				dir,
+				rawKind,
+				sourceField,
+				target,
+				source,
+				path,
+			)
+			if dir == DirTo && defaultValue != nil && !useNilPointer {
+				stmt = newIfZeroDefault(isZero, targetExpr, defaultValue, stmt)
+			}
+			decls[dir].Body.List = append(decls[dir].Body.List, stmt)
+		}
+	}
+
+	for dir, decl := range decls {
+		if ret := finalReturn(cfg, dir); ret != nil {
+			decl.Body.List = append(decl.Body.List, ret)
+		}
+	}
+
+	g.To = decls[DirTo]
+	g.From = decls[DirFrom]
+	if cfg.Constructors {
+		if g.To != nil {
+			g.NewTo = generateConstructorFunc(cfg, DirTo, targetType, ctxParam)
+		}
+		if g.From != nil {
+			g.NewFrom = generateConstructorFunc(cfg, DirFrom, targetType, ctxParam)
+		}
+	}
 
 	return g, fmtErrors("failed to generate", errs)
 }
 
+// fieldSide holds the expressions for one side of a field assignment.
+type fieldSide struct {
+	// Expr is the field selector.
+	Expr ast.Expr
+
+	// Type is the type of the field.
+	Type ast.Expr
+
+	// ElemType is the type of the elements of a slice or the values of a map.
+	ElemType ast.Expr
+}
+
+// newFieldAssignStmt returns the statement which assigns the field in the
+// given direction. The target is assigned from the source in the To
+// direction, and the source from the target in the From direction.
+func newFieldAssignStmt(
+	scope funcScope,
+	opts assignOptions,
+	dir Direction,
+	rawKind assignmentKind,
+	field fieldConfig,
+	target fieldSide,
+	source fieldSide,
+	path errPath,
+) ast.Stmt {
+	left, right := target, source
+	if dir == DirFrom {
+		left, right = source, target
+	}
+
+	switch kind := rawKind.(type) {
+	case *singleAssignmentKind:
+		return newAssignStmt(
+			scope,
+			opts,
+			left.Expr,
+			left.Type,
+			right.Expr,
+			right.Type,
+			field.ConvertFunc(dir),
+			kind.Builtin.For(dir),
+			kind.Direct,
+			kind.Convert,
+			path,
+		)
+	case *sliceAssignmentKind:
+		return newAssignStmtSlice(
+			scope,
+			opts,
+			left.Expr,
+			left.Type,
+			left.ElemType,
+			right.Expr,
+			right.ElemType,
+			field.ConvertFunc(dir),
+			field.UserElemFunc(dir),
+			kind.ElemBuiltin.For(dir),
+			kind.ElemDirect,
+			kind.ElemConvert,
+			path,
+		)
+	case *mapAssignmentKind:
+		return newAssignStmtMap(
+			scope,
+			opts,
+			left.Expr,
+			left.Type,
+			left.ElemType,
+			right.Expr,
+			right.ElemType,
+			field.ConvertFunc(dir),
+			field.UserElemFunc(dir),
+			kind.ElemBuiltin.For(dir),
+			kind.ElemDirect,
+			kind.ElemConvert,
+			path,
+		)
+	}
+	panic(fmt.Sprintf("unexpected assignment kind %T", rawKind))
+}
+
+// checkFieldFuncs checks that the functions used to convert the field can be
+// called from the generated functions. Functions which return an error can
+// only be called if the generated functions also return an error, and
+// functions which take a ctx can only be called if the generated functions
+// have a ctx of the same type. A field which uses user functions must have
+// one for every direction it is assigned in.
+func checkFieldFuncs(cfg structConfig, field fieldConfig) error {
+	for _, dir := range cfg.FieldDirections(field) {
+		// The annotation keys for the function of this direction, and of the
+		// other direction.
+		key, otherKey, otherDir := "func-to", "func-from", "from"
+		if dir == DirFrom {
+			key, otherKey, otherDir = "func-from", "func-to", "to"
+		}
+		msg := "uses %v without %v. Set both, or set direction=%v on the field."
+		switch {
+		case field.hasUserFuncs() && field.UserFunc(dir).Name == "":
+			return fmt.Errorf(msg, otherKey, key, otherDir)
+		case field.hasUserElemFuncs() && field.UserElemFunc(dir).Name == "":
+			return fmt.Errorf(msg, "elem-"+otherKey, "elem-"+key, otherDir)
+		}
+
+		for _, fn := range []valueFunc{field.UserFunc(dir), field.UserElemFunc(dir)} {
+			switch {
+			case fn.Errors && !cfg.Errors:
+				return fmt.Errorf("uses %v which returns an error. Set errors=true on struct %v.", fn.Name, cfg.Source)
+			case fn.Ctx && cfg.Ctx.IsZero():
+				return fmt.Errorf("uses %v which requires a ctx. Set ctx on struct %v.", fn.Name, cfg.Source)
+			}
+		}
+
+		fn := field.ConvertFunc(dir)
+		switch {
+		case fn.Skipped:
+			return fmt.Errorf("uses %v which is not generated. Check the direction of the struct it converts.", fn.Name)
+		case fn.Errors && !cfg.Errors:
+			return fmt.Errorf("uses %v which returns an error. Set errors=true on struct %v.", fn.Name, cfg.Source)
+		case !fn.Ctx.IsZero() && fn.Ctx != cfg.Ctx:
+			return fmt.Errorf("uses %v which requires a ctx of type %v. Set ctx=%v on struct %v.",
+				fn.Name, fn.Ctx, fn.Ctx, cfg.Source)
+		}
+	}
+	return nil
+}
+
+// checkUserFuncSigs checks that the user supplied functions of the field take
+// the type they are called with, and the ctx of the struct when they take two
+// arguments, and return the type they are assigned to. Names which refer to
+// types are checked as type conversions.
+func checkUserFuncSigs(cfg structConfig, field fieldConfig, targetType types.Type) error {
+	type check struct {
+		key, name     string
+		param, result types.Type
+	}
+	var checks []check
+	for _, dir := range cfg.FieldDirections(field) {
+		param, result := field.SourceType, targetType
+		key := "func-to"
+		if dir == DirFrom {
+			param, result = result, param
+			key = "func-from"
+		}
+		checks = append(checks, check{key: key, name: field.UserFuncName(dir), param: param, result: result})
+
+		if paramElem, resultElem, ok := collectionElems(param, result); ok {
+			checks = append(checks, check{
+				key:    "elem-" + key,
+				name:   field.UserElemFuncName(dir),
+				param:  paramElem,
+				result: resultElem,
+			})
+		}
+	}
+
+	for _, c := range checks {
+		switch obj := field.UserFuncObjs[c.name].(type) {
+		case *types.TypeName:
+			if !convertibleType(c.param, obj.Type()) {
+				return fmt.Errorf("uses %v=%v which can not convert %v to %v", c.key, c.name, c.param, obj.Type())
+			}
+			if !assignableType(obj.Type(), c.result) {
+				return fmt.Errorf("uses %v=%v which converts to %v, not %v", c.key, c.name, obj.Type(), c.result)
+			}
+		case *types.Func:
+			sig := obj.Type().(*types.Signature)
+			params, results := sig.Params(), sig.Results()
+			switch {
+			case params.Len() != 1 && params.Len() != 2:
+				return fmt.Errorf("uses %v=%v which must take one argument, or a ctx and one argument", c.key, c.name)
+			case results.Len() != 1 && !(results.Len() == 2 && returnsError(sig)):
+				return fmt.Errorf("uses %v=%v which must return one value, or a value and an error", c.key, c.name)
+			}
+			if arg := params.At(params.Len() - 1).Type(); !assignableType(c.param, arg) {
+				return fmt.Errorf("uses %v=%v which takes %v, not %v", c.key, c.name, arg, c.param)
+			}
+			if params.Len() == 2 && !cfg.Ctx.IsZero() && !cfg.Ctx.Matches(params.At(0).Type(), obj.Pkg()) {
+				return fmt.Errorf("uses %v=%v which takes a ctx of type %v, not %v",
+					c.key, c.name, params.At(0).Type(), cfg.Ctx)
+			}
+			if res := results.At(0).Type(); !assignableType(res, c.result) {
+				return fmt.Errorf("uses %v=%v which returns %v, not %v", c.key, c.name, res, c.result)
+			}
+		}
+	}
+	return nil
+}
+
+// collectionElems returns the types of the elements of two slices, or the
+// values of two maps.
+func collectionElems(a, b types.Type) (types.Type, types.Type, bool) {
+	switch x := a.Underlying().(type) {
+	case *types.Slice:
+		if y, ok := b.Underlying().(*types.Slice); ok {
+			return x.Elem(), y.Elem(), true
+		}
+	case *types.Map:
+		if y, ok := b.Underlying().(*types.Map); ok {
+			return x.Elem(), y.Elem(), true
+		}
+	}
+	return nil, nil, false
+}
+
+// assignableType returns true if a value of the from type can be assigned to
+// the to type. Types from the target packages are compared by name, because
+// they are loaded separately from the source package.
+func assignableType(from, to types.Type) bool {
+	return sameType(from, to) || types.AssignableTo(from, to)
+}
+
+// convertibleType returns true if a value of the from type can be converted
+// to the to type.
+func convertibleType(from, to types.Type) bool {
+	return sameType(from.Underlying(), to.Underlying()) || types.ConvertibleTo(from, to)
+}
+
+// fieldError returns the error for the field, which is named by the target
+// field name, prefixed by the position of the field when it is known.
+func fieldError(cfg structConfig, field fieldConfig, name string, err error) error {
+	if field.Pos.IsValid() {
+		return fmt.Errorf("%v: struct %v field %v %w", field.Pos, cfg.Source, name, err)
+	}
+	return fmt.Errorf("struct %v field %v %w", cfg.Source, name, err)
+}
+
+// fieldDefault returns the default value of the field, with the packages it
+// refers to added to imports, and the expression which is true when the
+// source field is the zero value. Returns an error if the default can not be
+// assigned to the target type.
+func fieldDefault(cfg structConfig, field fieldConfig, targetType types.Type, srcExpr ast.Expr, imports *imports) (ast.Expr, ast.Expr, error) {
+	if cfg.Direction == DirFrom || field.Direction == DirFrom {
+		return nil, nil, fmt.Errorf("uses default which is only assigned in the To direction")
+	}
+	if !defaultAssignable(field, targetType) {
+		return nil, nil, fmt.Errorf("uses default=%v of type %v which can not be assigned to %v",
+			field.Default, field.DefaultType, targetType)
+	}
+
+	isZero := astIsZero(srcExpr, field.SourceType, field.SourceExpr)
+	if isZero == nil {
+		return nil, nil, fmt.Errorf("uses default but the zero value of its type can not be compared")
+	}
+
+	value, err := parser.ParseExpr(field.Default)
+	if err != nil {
+		return nil, nil, fmt.Errorf("has an invalid default: %w", err)
+	}
+
+	// Parentheses are only needed to keep the spaces of an expression in
+	// the annotation.
+	if paren, ok := value.(*ast.ParenExpr); ok {
+		value = paren.X
+	}
+
+	rewriteQualifiers(value, field.DefaultImports, imports)
+	return value, isZero, nil
+}
+
+// defaultAssignable returns true if the default of the field can be assigned
+// to the target type. An untyped constant can be assigned when its value can
+// be represented by the target type, which is checked by type checking a
+// function which returns the value as the basic type underlying the target.
+func defaultAssignable(field fieldConfig, targetType types.Type) bool {
+	if field.DefaultType == nil {
+		return true
+	}
+	untyped, ok := field.DefaultType.(*types.Basic)
+	if !ok || untyped.Info()&types.IsUntyped == 0 || field.DefaultValue == nil {
+		return assignableType(field.DefaultType, targetType)
+	}
+	basic, ok := targetType.Underlying().(*types.Basic)
+	if !ok {
+		return assignableType(field.DefaultType, targetType)
+	}
+	expr := fmt.Sprintf("func() %v { return %v }", basic.Name(), field.DefaultValue.ExactString())
+	_, err := types.Eval(token.NewFileSet(), nil, token.NoPos, expr)
+	return err == nil
+}
+
+// rewriteQualifiers adds the packages referred to by expr to imports, and
+// replaces the qualifiers in expr with the names of the imports in the
+// generated file. pkgs are the package paths keyed by qualifier.
+func rewriteQualifiers(expr ast.Expr, pkgs map[string]string, imports *imports) {
+	ast.Inspect(expr, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if pkgPath, ok := pkgs[ident.Name]; ok {
+			imports.Add("", pkgPath)
+			ident.Name = imports.AliasFor(pkgPath)
+		}
+		return true
+	})
+}
+
+// checkBuiltin checks that the builtin conversion used to assign the field,
+// if any, can be called from the generated functions.
+func checkBuiltin(cfg structConfig, field fieldConfig, rawKind assignmentKind) error {
+	var builtin *builtinConversion
+	switch kind := rawKind.(type) {
+	case *singleAssignmentKind:
+		builtin = kind.Builtin
+	case *sliceAssignmentKind:
+		builtin = kind.ElemBuiltin
+	case *mapAssignmentKind:
+		builtin = kind.ElemBuiltin
+	}
+	for _, dir := range cfg.FieldDirections(field) {
+		if fn := builtin.For(dir); fn.Errors && !cfg.Errors && field.OnError == "" {
+			return fmt.Errorf("uses %v which returns an error. Set errors=true on struct %v, or on-error on the field.", fn.Expr, cfg.Source)
+		}
+	}
+	return nil
+}
+
+// checkEnum returns the pairs of constants used to convert the enum field,
+// and checks that the generated functions can return an error if the fallback
+// requires it. local is the path of the package of the generated code.
+func checkEnum(cfg structConfig, field fieldConfig, targetType types.Type, local string) ([]enumPair, error) {
+	if field.EnumFallback == enumFallbackError && !cfg.Errors {
+		return nil, fmt.Errorf("uses enum-fallback=error which returns an error. Set errors=true on struct %v.", cfg.Source)
+	}
+	return computeEnumPairs(field.Enum, targetType, field.SourceType, field.EnumMap, local)
+}
+
+// checkNilPointerFunc checks that the function set by nil-pointer=func:<name>
+// takes no arguments, and returns a value which can be assigned to the field,
+// or its elements, in every direction where they are assigned from a nil
+// pointer or an empty value.
+func checkNilPointerFunc(cfg structConfig, field fieldConfig, rawKind assignmentKind) error {
+	if field.NilPointer.Mode != nilPointerFunc {
+		return nil
+	}
+	name := field.NilPointer.Func
+	var sig *types.Signature
+	switch obj := field.UserFuncObjs[name].(type) {
+	case nil:
+		// The function is resolved when the source package is loaded.
+		return nil
+	case *types.Func:
+		sig = obj.Type().(*types.Signature)
+	default:
+		return fmt.Errorf("uses nil-pointer=func:%v which is not a function", name)
+	}
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return fmt.Errorf("uses nil-pointer=func:%v which must take no arguments and return one value", name)
+	}
+	result := sig.Results().At(0).Type()
+
+	var left, right types.Type
+	var builtin *builtinConversion
+	switch kind := rawKind.(type) {
+	case *singleAssignmentKind:
+		left, right, builtin = kind.Left, kind.Right, kind.Builtin
+	case *sliceAssignmentKind:
+		left, right, builtin = kind.LeftElem, kind.RightElem, kind.ElemBuiltin
+	case *mapAssignmentKind:
+		left, right, builtin = kind.LeftElem, kind.RightElem, kind.ElemBuiltin
+	default:
+		return nil
+	}
+	for _, dir := range cfg.FieldDirections(field) {
+		assigned, from := left, right
+		if dir == DirFrom {
+			assigned, from = right, left
+		}
+		if !isPointer(from) && builtin.For(dir).Cond == "" {
+			// The function is not called in this direction.
+			continue
+		}
+		if !assignableType(result, assigned) {
+			return fmt.Errorf("uses nil-pointer=func:%v which returns %v, not %v", name, result, assigned)
+		}
+	}
+	return nil
+}
+
+// checkLossy checks that numeric type conversions used to assign the field,
+// which may lose data, are allowed by the lossy annotation of the field.
+func checkLossy(cfg structConfig, field fieldConfig, rawKind assignmentKind) error {
+	for _, dir := range cfg.FieldDirections(field) {
+		conv, ok := numericConversionFor(rawKind, dir)
+		if !ok || !conv.Lossy() {
+			continue
+		}
+		switch {
+		case field.Lossy == "":
+			return fmt.Errorf("converts %v in the %v direction which is a %v conversion and may lose data. Set lossy=allow or lossy=check.",
+				conv, dir, conv.Class())
+		case field.Lossy == lossyCheck && !cfg.Errors:
+			return fmt.Errorf("uses lossy=check which returns an error. Set errors=true on struct %v.", cfg.Source)
+		}
+	}
+	return nil
+}
+
+// runeConversionError returns the reason the field is not converted when
+// the field, or the elements of slice and map fields, are integers on one side
+// and strings on the other, or nil if they are not.
+func runeConversionError(left, right types.Type) error {
+	left, right = derefType(left), derefType(right)
+	switch l := left.Underlying().(type) {
+	case *types.Slice:
+		if r, ok := right.Underlying().(*types.Slice); ok {
+			left, right = derefType(l.Elem()), derefType(r.Elem())
+		}
+	case *types.Map:
+		if r, ok := right.Underlying().(*types.Map); ok {
+			left, right = derefType(l.Elem()), derefType(r.Elem())
+		}
+	}
+	if !isRuneConversion(left, right) {
+		return nil
+	}
+	return fmt.Errorf("a conversion from %v to %v would convert integers to runes, not their digits. "+
+		"Set enum=order or enum=name to convert the constants of an enum, or set func-to and func-from.", right, left)
+}
+
+// derefType returns the element of a pointer type, or the type.
+func derefType(t types.Type) types.Type {
+	if ptr, ok := t.(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
+
+// numericConversionFor returns the numeric type conversion used to assign the
+// field, or its elements, in the given direction, if there is one.
+func numericConversionFor(rawKind assignmentKind, dir Direction) (numericConversion, bool) {
+	var left, right types.Type
+	switch kind := rawKind.(type) {
+	case *singleAssignmentKind:
+		if !kind.Convert {
+			return numericConversion{}, false
+		}
+		left, right = kind.Left, kind.Right
+	case *sliceAssignmentKind:
+		if !kind.ElemConvert {
+			return numericConversion{}, false
+		}
+		left, right = kind.LeftElem, kind.RightElem
+	case *mapAssignmentKind:
+		if !kind.ElemConvert {
+			return numericConversion{}, false
+		}
+		left, right = kind.LeftElem, kind.RightElem
+	}
+	if left == nil {
+		return numericConversion{}, false
+	}
+	if dir == DirFrom {
+		left, right = right, left
+	}
+	return newNumericConversion(left, right)
+}
+
 func sourceFieldMap(fields []fieldConfig) map[string]fieldConfig {
 	result := make(map[string]fieldConfig, len(fields))
 	for _, field := range fields {
@@ -297,74 +1157,232 @@ type generated struct {
 	To   *ast.FuncDecl
 	From *ast.FuncDecl
 
+	// To and From are nil when the struct is only converted in the other
+	// direction. NewTo and NewFrom are only generated when constructors are
+	// enabled.
+	NewTo   *ast.FuncDecl
+	NewFrom *ast.FuncDecl
+
 	// TODO: RoundTripTest *ast.FuncDecl
 }
 
-func generateToFunc(cfg structConfig, targetType *ast.SelectorExpr) *ast.FuncDecl {
+func generateToFunc(cfg structConfig, targetType *ast.SelectorExpr, ctxParam *ast.Field) *ast.FuncDecl {
 	funcName := cfg.ConvertFuncName(DirTo)
+	results, nilReturn := funcResults(cfg, DirTo, targetType)
+
+	if cfg.Methods {
+		// The method allocates the target, which is returned by finalReturn.
+		return &ast.FuncDecl{
+			Recv: &ast.FieldList{List: []*ast.Field{
+				{
+					Names: []*ast.Ident{{Name: varNameSource}},
+					Type:  newPointerTo(cfg.Source),
+				},
+			}},
+			Name: &ast.Ident{Name: funcName},
+			Type: &ast.FuncType{
+				Params:  funcParams(ctxParam),
+				Results: results,
+			},
+			Body: &ast.BlockStmt{List: []ast.Stmt{
+				newIfNilReturn(varNameSource, nilReturn...),
+				// t := new(<targetType>)
+				&ast.AssignStmt{
+					Lhs: []ast.Expr{&ast.Ident{Name: varNameTarget}},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{&ast.CallExpr{
+						Fun:  &ast.Ident{Name: "new"},
+						Args: []ast.Expr{targetType},
+					}},
+				},
+			}},
+		}
+	}
 
 	return &ast.FuncDecl{
 		Name: &ast.Ident{Name: funcName},
 		Type: &ast.FuncType{
-			Params: &ast.FieldList{
-				List: []*ast.Field{
-					{
-						Names: []*ast.Ident{{Name: varNameSource}},
-						Type:  newPointerTo(cfg.Source),
-					},
-					{
-						Names: []*ast.Ident{{Name: varNameTarget}},
-						Type: &ast.StarExpr{
-							X: targetType,
-						},
+			Params: funcParams(ctxParam,
+				&ast.Field{
+					Names: []*ast.Ident{{Name: varNameSource}},
+					Type:  newPointerTo(cfg.Source),
+				},
+				&ast.Field{
+					Names: []*ast.Ident{{Name: varNameTarget}},
+					Type: &ast.StarExpr{
+						X: targetType,
 					},
 				},
-			},
+			),
+			Results: results,
 		},
 		Body: &ast.BlockStmt{List: []ast.Stmt{
-			newIfNilReturn(varNameSource),
+			newIfNilReturn(varNameSource, nilReturn...),
 			// TODO: fill in contents here
 		}},
 	}
 }
 
-func generateFromFunc(cfg structConfig, targetType *ast.SelectorExpr) *ast.FuncDecl {
+func generateFromFunc(cfg structConfig, targetType *ast.SelectorExpr, ctxParam *ast.Field) *ast.FuncDecl {
 	funcName := cfg.ConvertFuncName(DirFrom)
+	results, nilReturn := funcResults(cfg, DirFrom, targetType)
 
-	return &ast.FuncDecl{
+	targetParam := &ast.Field{
+		Names: []*ast.Ident{{Name: varNameTarget}},
+		Type: &ast.StarExpr{
+			X: targetType,
+		},
+	}
+	sourceParam := &ast.Field{
+		Names: []*ast.Ident{{Name: varNameSource}},
+		Type:  newPointerTo(cfg.Source),
+	}
+
+	decl := &ast.FuncDecl{
 		Name: &ast.Ident{Name: funcName},
 		Type: &ast.FuncType{
-			Params: &ast.FieldList{
-				List: []*ast.Field{
-					{
-						Names: []*ast.Ident{{Name: varNameTarget}},
-						Type: &ast.StarExpr{
-							X: targetType,
-						},
-					},
-					{
-						Names: []*ast.Ident{{Name: varNameSource}},
-						Type:  newPointerTo(cfg.Source),
-					},
-				},
-			},
+			Params:  funcParams(ctxParam, targetParam, sourceParam),
+			Results: results,
 		},
 		Body: &ast.BlockStmt{
 			List: []ast.Stmt{
-				newIfNilReturn(varNameSource),
+				newIfNilReturn(varNameSource, nilReturn...),
 				// TODO: fill in contents here
 			},
 		},
 	}
+	if cfg.Methods {
+		// The source is the receiver, which is filled in from the target.
+		decl.Recv = &ast.FieldList{List: []*ast.Field{sourceParam}}
+		decl.Type.Params = funcParams(ctxParam, targetParam)
+	}
+	return decl
 }
 
-func astWriteToFile(path string, fset *token.FileSet, file *ast.File) error {
-	out, err := astToBytes(fset, file)
-	if err != nil {
-		return err
+// generateConstructorFunc generates a function which allocates the converted
+// value, fills it in by calling the conversion function for the direction,
+// and returns it.
+func generateConstructorFunc(
+	cfg structConfig,
+	direction Direction,
+	targetType *ast.SelectorExpr,
+	ctxParam *ast.Field,
+) *ast.FuncDecl {
+	in, out := varNameSource, varNameTarget
+	var inType, outType ast.Expr = &ast.Ident{Name: cfg.Source}, targetType
+	if direction == DirFrom {
+		in, out = out, in
+		inType, outType = outType, inType
+	}
+
+	results := []*ast.Field{{Type: &ast.StarExpr{X: outType}}}
+	nilReturn := []ast.Expr{&ast.Ident{Name: "nil"}}
+	if cfg.Errors {
+		results = append(results, &ast.Field{Type: &ast.Ident{Name: "error"}})
+		nilReturn = append(nilReturn, &ast.Ident{Name: "nil"})
+	}
+
+	args := []ast.Expr{&ast.Ident{Name: in}, newAddressOf(out)}
+	if ctxParam != nil {
+		args = append([]ast.Expr{&ast.Ident{Name: varNameCtx}}, args...)
+	}
+	call := &ast.CallExpr{
+		Fun:  &ast.Ident{Name: cfg.ConvertFuncName(direction)},
+		Args: args,
+	}
+
+	// if <in> == nil {
+	// 	return nil
+	// }
+	// var <out> <outType>
+	// <convertFunc>(<in>, &<out>)
+	// return &<out>
+	body := []ast.Stmt{
+		newIfNilReturn(in, nilReturn...),
+		astDeclare(out, outType),
+	}
+	if cfg.Errors {
+		body = append(body,
+			&ast.IfStmt{
+				Init: &ast.AssignStmt{
+					Lhs: []ast.Expr{&ast.Ident{Name: varNameErr}},
+					Tok: token.DEFINE,
+					Rhs: []ast.Expr{call},
+				},
+				Cond: astIsNotNil(&ast.Ident{Name: varNameErr}),
+				Body: &ast.BlockStmt{List: []ast.Stmt{
+					&ast.ReturnStmt{Results: []ast.Expr{
+						&ast.Ident{Name: "nil"},
+						&ast.Ident{Name: varNameErr},
+					}},
+				}},
+			},
+			&ast.ReturnStmt{Results: []ast.Expr{newAddressOf(out), &ast.Ident{Name: "nil"}}},
+		)
+	} else {
+		body = append(body,
+			&ast.ExprStmt{X: call},
+			&ast.ReturnStmt{Results: []ast.Expr{newAddressOf(out)}},
+		)
+	}
+
+	return &ast.FuncDecl{
+		Name: &ast.Ident{Name: cfg.ConstructorFuncName(direction)},
+		Type: &ast.FuncType{
+			Params: funcParams(ctxParam, &ast.Field{
+				Names: []*ast.Ident{{Name: in}},
+				Type:  &ast.StarExpr{X: inType},
+			}),
+			Results: &ast.FieldList{List: results},
+		},
+		Body: &ast.BlockStmt{List: body},
+	}
+}
+
+// funcParams returns the parameters of a generated conversion function, with
+// the ctx parameter first if there is one.
+func funcParams(ctxParam *ast.Field, params ...*ast.Field) *ast.FieldList {
+	if ctxParam != nil {
+		params = append([]*ast.Field{ctxParam}, params...)
+	}
+	return &ast.FieldList{List: params}
+}
+
+// funcResults returns the results of the generated conversion functions, and
+// the values to return when there is nothing to convert.
+func funcResults(cfg structConfig, direction Direction, targetType *ast.SelectorExpr) (*ast.FieldList, []ast.Expr) {
+	var (
+		results   []*ast.Field
+		nilReturn []ast.Expr
+	)
+	if cfg.Methods && direction == DirTo {
+		results = append(results, &ast.Field{Type: &ast.StarExpr{X: targetType}})
+		nilReturn = append(nilReturn, &ast.Ident{Name: "nil"})
+	}
+	if cfg.Errors {
+		results = append(results, &ast.Field{Type: &ast.Ident{Name: "error"}})
+		nilReturn = append(nilReturn, &ast.Ident{Name: "nil"})
 	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return &ast.FieldList{List: results}, nilReturn
+}
 
-	return writeFile(path, out)
+// finalReturn returns the statement which ends the generated conversion
+// function, or nil if the function does not return anything.
+func finalReturn(cfg structConfig, direction Direction) ast.Stmt {
+	var results []ast.Expr
+	if cfg.Methods && direction == DirTo {
+		results = append(results, &ast.Ident{Name: varNameTarget})
+	}
+	if cfg.Errors {
+		results = append(results, &ast.Ident{Name: "nil"})
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	return &ast.ReturnStmt{Results: results}
 }
 
 func astToBytes(fset *token.FileSet, file *ast.File) ([]byte, error) {
@@ -392,9 +1410,6 @@ func writeFile(output string, contents []byte) error {
 	}
 	defer fh.Close()
 
-	if _, err := fmt.Fprint(fh, "// Code generated by mog. DO NOT EDIT.\n\n"); err != nil {
-		return err
-	}
 	if _, err := fh.Write(contents); err != nil {
 		return err
 	}
@@ -406,6 +1421,10 @@ type imports struct {
 	byPkgPath map[string]string   // package => alias(or default)
 	byAlias   map[string]string   // alias(or default) => package
 	hasAlias  map[string]struct{} // package is using a non-default name
+
+	// local is the package the file is generated in. When it is set the
+	// packages of other types are imported as they are used.
+	local string
 }
 
 func newImports() *imports {
@@ -456,6 +1475,90 @@ func (i *imports) AliasFor(pkgPath string) string {
 	return i.byPkgPath[pkgPath]
 }
 
+// RemoveUnused removes the imports which are not used by the declarations.
+// Types are imported when they are converted to expressions, which are not
+// always used. A qualifier in the body of a function is not an import when it
+// is the name of a parameter or local variable, like s.Name.
+func (i *imports) RemoveUnused(decls []ast.Decl) {
+	used := make(map[string]struct{})
+	addUsed := func(node ast.Node, locals map[string]struct{}) {
+		ast.Inspect(node, func(node ast.Node) bool {
+			if sel, ok := node.(*ast.SelectorExpr); ok {
+				if ident, ok := sel.X.(*ast.Ident); ok {
+					if _, ok := locals[ident.Name]; !ok {
+						used[ident.Name] = struct{}{}
+					}
+				}
+			}
+			return true
+		})
+	}
+	for _, decl := range decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			addUsed(decl, nil)
+			continue
+		}
+		if fn.Recv != nil {
+			addUsed(fn.Recv, nil)
+		}
+		addUsed(fn.Type, nil)
+		if fn.Body != nil {
+			addUsed(fn.Body, localNames(fn))
+		}
+	}
+
+	for pkgPath, alias := range i.byPkgPath {
+		if _, ok := used[alias]; ok {
+			continue
+		}
+		delete(i.byPkgPath, pkgPath)
+		delete(i.byAlias, alias)
+		delete(i.hasAlias, pkgPath)
+	}
+}
+
+// localNames returns the names of the receiver, parameters, results and
+// variables declared in the function.
+func localNames(fn *ast.FuncDecl) map[string]struct{} {
+	names := make(map[string]struct{})
+	addIdents := func(exprs ...ast.Expr) {
+		for _, expr := range exprs {
+			if ident, ok := expr.(*ast.Ident); ok {
+				names[ident.Name] = struct{}{}
+			}
+		}
+	}
+	for _, fields := range []*ast.FieldList{fn.Recv, fn.Type.Params, fn.Type.Results} {
+		if fields == nil {
+			continue
+		}
+		for _, field := range fields.List {
+			for _, name := range field.Names {
+				addIdents(name)
+			}
+		}
+	}
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				addIdents(n.Lhs...)
+			}
+		case *ast.RangeStmt:
+			if n.Tok == token.DEFINE {
+				addIdents(n.Key, n.Value)
+			}
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				addIdents(name)
+			}
+		}
+		return true
+	})
+	return names
+}
+
 func (i *imports) Decl() *ast.GenDecl {
 	decl := &ast.GenDecl{Tok: token.IMPORT}
 
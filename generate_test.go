@@ -4,14 +4,26 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/printer"
 	"go/token"
 	"go/types"
 	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/packages"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/golden"
 )
@@ -60,6 +72,1000 @@ func TestConfigsByOutput(t *testing.T) {
 }
 
 func TestGenerateConversion(t *testing.T) {
+	// The configs and targets are of the Node struct, which is converted to
+	// the Node struct of the core package, so the cases only set the fields
+	// and options which matter.
+	type testCase struct {
+		name   string
+		cfg    structConfig
+		target []*types.Var
+	}
+	run := func(t *testing.T, tc testCase) {
+		c := tc.cfg
+		c.Source = "Node"
+		c.FuncNameFragment = "Core"
+		c.Target = target{Package: "example.com/org/project/core", Struct: "Node"}
+
+		imports := newImports()
+		imports.local = "example.com/org/project/src"
+		gen, err := generateConversion(c, targetStruct{Name: "Node", Fields: tc.target}, imports)
+		assert.NilError(t, err)
+
+		var decls []ast.Decl
+		for _, decl := range []*ast.FuncDecl{gen.To, gen.From, gen.NewTo, gen.NewFrom} {
+			if decl != nil {
+				decls = append(decls, decl)
+			}
+		}
+		imports.RemoveUnused(decls)
+
+		file := &ast.File{Name: &ast.Ident{Name: "src"}}
+		file.Decls = append([]ast.Decl{imports.Decl()}, decls...)
+		out, err := astToBytes(&token.FileSet{}, file)
+		assert.NilError(t, err)
+
+		if *shouldPrint {
+			t.Logf("OUTPUT\n%s\n", PrependLineNumbers(string(out)))
+		}
+		golden.Assert(t, string(out), strings.ReplaceAll(t.Name(), "/", "_")+"-expected")
+	}
+
+	testCases := []testCase{
+		{
+			name: "Basic",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName: "Iden",
+				SourceExpr: &ast.Ident{Name: "string"},
+				TargetName: "ID",
+				SourceType: types.Typ[types.String],
+			}}},
+			target: []*types.Var{newField("ID", types.Typ[types.String])},
+		},
+		{
+			name: "TextMarshaler",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName: "Region",
+				SourceExpr: &ast.Ident{Name: "string"},
+				SourceType: types.Typ[types.String],
+				OnError:    onErrorPanic,
+			}}},
+			target: []*types.Var{newField("Region", newTextType("example.com/org/project/core", "Region"))},
+		},
+		{
+			name: "OnErrorIgnore",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName: "Endpoint",
+				SourceExpr: &ast.Ident{Name: "string"},
+				SourceType: types.Typ[types.String],
+				OnError:    onErrorIgnore,
+			}}},
+			target: []*types.Var{newField("Endpoint", newNamedStruct("net/url", "URL"))},
+		},
+		{
+			name: "Methods",
+			cfg: structConfig{
+				Methods: true,
+				Fields: []fieldConfig{
+					{
+						SourceName: "Iden",
+						SourceExpr: &ast.Ident{Name: "string"},
+						TargetName: "ID",
+						SourceType: types.Typ[types.String],
+					},
+					{
+						SourceName:      "Work",
+						SourceExpr:      &ast.Ident{Name: "Workload"},
+						SourceType:      newNamedStruct("example.com/org/project/src", "Workload"),
+						ConvertFuncTo:   convertFunc{Name: "ToCore", Method: true, Direction: DirTo},
+						ConvertFuncFrom: convertFunc{Name: "FromCore", Method: true, Direction: DirFrom},
+					},
+				},
+			},
+			target: []*types.Var{
+				newField("ID", types.Typ[types.String]),
+				newField("Work", newNamedStruct("example.com/org/project/core", "Workload")),
+			},
+		},
+		{
+			// Only generating the From direction does not require IdenToCore,
+			// so only NodeFromCore is generated.
+			name: "SkippedConvertFunc",
+			cfg: structConfig{
+				Direction: DirFrom,
+				Fields: []fieldConfig{{
+					SourceName:      "Iden",
+					SourceExpr:      &ast.Ident{Name: "string"},
+					TargetName:      "ID",
+					SourceType:      types.Typ[types.String],
+					ConvertFuncTo:   convertFunc{Name: "IdenToCore", Skipped: true},
+					ConvertFuncFrom: convertFunc{Name: "IdenFromCore"},
+				}},
+			},
+			target: []*types.Var{newField("ID", types.Typ[types.String])},
+		},
+		{
+			name: "WithDefault",
+			cfg: structConfig{Fields: []fieldConfig{
+				timeoutField("time.Second", duration),
+			}},
+			target: []*types.Var{newField("Timeout", duration)},
+		},
+		{
+			// Parentheses keep the spaces of the expression in the
+			// annotation, and are not needed in the generated code.
+			name: "WithDefaultExpression",
+			cfg: structConfig{Fields: []fieldConfig{
+				timeoutField("(time.Second * 30)", duration),
+			}},
+			target: []*types.Var{newField("Timeout", duration)},
+		},
+		{
+			// The constants are not in alphabetical order, to check that they
+			// are paired in the order they are declared. The unexported
+			// constant of the core package can not be referred to from the
+			// source package, so it is not paired.
+			name: "Enum",
+			cfg: structConfig{Fields: []fieldConfig{
+				kindField(enumOrder, "Kind_B", "Kind_A"),
+			}},
+			target: []*types.Var{newField("Kind", coreKind)},
+		},
+		{
+			// Functions with other signatures are not added.
+			name: "Funcs",
+			cfg: structConfig{
+				Fields: []fieldConfig{locationField},
+				Funcs:  locationFuncs(newFunc(nil, "example.com/org/project/helpers", "Other", nil, nil)),
+			},
+			target: []*types.Var{newField("Location", coreLocation)},
+		},
+		{
+			// The functions convert the elements of slices and the values of
+			// maps when there are none for the whole field.
+			name: "FuncsForElements",
+			cfg: structConfig{
+				Fields: []fieldConfig{
+					{
+						SourceName: "Locations",
+						SourceExpr: &ast.ArrayType{Elt: &ast.Ident{Name: "Location"}},
+						SourceType: types.NewSlice(srcLocation),
+					},
+					{
+						SourceName: "ByName",
+						SourceExpr: &ast.MapType{Key: &ast.Ident{Name: "string"}, Value: &ast.Ident{Name: "Location"}},
+						SourceType: types.NewMap(types.Typ[types.String], srcLocation),
+					},
+				},
+				Funcs: locationFuncs(),
+			},
+			target: []*types.Var{
+				newField("Locations", types.NewSlice(coreLocation)),
+				newField("ByName", types.NewMap(types.Typ[types.String], coreLocation)),
+			},
+		},
+		{
+			name: "Constructors",
+			cfg: structConfig{
+				Constructors: true,
+				Fields: []fieldConfig{{
+					SourceName: "Iden",
+					SourceExpr: &ast.Ident{Name: "string"},
+					TargetName: "ID",
+					SourceType: types.Typ[types.String],
+				}},
+			},
+			target: []*types.Var{newField("ID", types.Typ[types.String])},
+		},
+		{
+			name: "ConstructorsWithCtx",
+			cfg: structConfig{
+				Constructors: true,
+				Errors:       true,
+				Ctx:          newCtxType("context.Context"),
+				Fields: []fieldConfig{{
+					SourceName: "Iden",
+					SourceExpr: &ast.Ident{Name: "string"},
+					TargetName: "ID",
+					SourceType: types.Typ[types.String],
+				}},
+			},
+			target: []*types.Var{newField("ID", types.Typ[types.String])},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestGenerateConversion_Errors(t *testing.T) {
+	// The cases are of the Node struct, like TestGenerateConversion. An empty
+	// expected error checks that the conversion is generated.
+	type testCase struct {
+		name     string
+		cfg      structConfig
+		target   []*types.Var
+		expected string
+	}
+	run := func(t *testing.T, tc testCase) {
+		c := tc.cfg
+		c.Source = "Node"
+		c.FuncNameFragment = "Core"
+		c.Target = target{Package: "example.com/org/project/core", Struct: "Node"}
+
+		_, err := generateConversion(c, targetStruct{Name: "Node", Fields: tc.target}, newImports())
+		if tc.expected == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	idField := fieldConfig{
+		SourceName: "Iden",
+		SourceExpr: &ast.Ident{Name: "string"},
+		TargetName: "ID",
+		SourceType: types.Typ[types.String],
+	}
+	regionField := fieldConfig{
+		SourceName: "Region",
+		SourceExpr: &ast.Ident{Name: "string"},
+		SourceType: types.Typ[types.String],
+	}
+
+	// weightField returns a *int field which is assigned the result of fn
+	// when it is nil.
+	weightField := func(fn *types.Func) fieldConfig {
+		return fieldConfig{
+			SourceName:   "Weight",
+			SourceExpr:   &ast.StarExpr{X: &ast.Ident{Name: "int"}},
+			SourceType:   types.NewPointer(types.Typ[types.Int]),
+			NilPointer:   nilPointerPolicy{Mode: nilPointerFunc, Func: fn.Name()},
+			UserFuncObjs: map[string]types.Object{fn.Name(): fn},
+		}
+	}
+
+	// weightInt32Field returns an int32 field with the lossy mode.
+	weightInt32Field := func(lossy lossyMode) fieldConfig {
+		return fieldConfig{
+			SourceName: "Weight",
+			SourceExpr: &ast.Ident{Name: "int32"},
+			SourceType: types.Typ[types.Int32],
+			Lossy:      lossy,
+		}
+	}
+
+	coreID := newNamedStruct("example.com/org/project/core", "ID")
+	// userFuncField returns a string field which is converted to coreID by
+	// the user functions.
+	userFuncField := func(funcTo, funcFrom types.Object) fieldConfig {
+		return fieldConfig{
+			SourceName: "ID",
+			SourceExpr: &ast.Ident{Name: "string"},
+			SourceType: types.Typ[types.String],
+			FuncTo:     funcTo.Name(),
+			FuncFrom:   funcFrom.Name(),
+			UserFuncObjs: map[string]types.Object{
+				funcTo.Name():   funcTo,
+				funcFrom.Name(): funcFrom,
+			},
+		}
+	}
+	str := []types.Type{types.Typ[types.String]}
+	toCore := newFunc(nil, "example.com/org/project/src", "IDToCore", str, []types.Type{coreID})
+	fromCore := newFunc(nil, "example.com/org/project/src", "IDFromCore", []types.Type{coreID}, str)
+	options := types.NewPointer(newNamedStruct("example.com/org/project/src", "Options"))
+	ctxToCore := newFunc(nil, "example.com/org/project/src", "IDToCore",
+		[]types.Type{options, types.Typ[types.String]}, []types.Type{coreID})
+	ctxFromCore := newFunc(nil, "example.com/org/project/src", "IDFromCore",
+		[]types.Type{options, coreID}, str)
+	// serviceKind is a string enum without constants.
+	serviceKind := types.NewNamed(
+		types.NewTypeName(0, types.NewPackage("example.com/org/project/core", "core"), "ServiceKind", nil),
+		types.Typ[types.String],
+		nil)
+	kindInt32Field := fieldConfig{
+		SourceName: "Kind",
+		SourceExpr: &ast.Ident{Name: "int32"},
+		SourceType: types.Typ[types.Int32],
+	}
+	testCases := []testCase{
+		{
+			name: "missing source field",
+			cfg:  structConfig{Fields: []fieldConfig{idField}},
+			target: []*types.Var{
+				newField("ID", types.Typ[types.String]),
+				newField("Name", types.Typ[types.String]),
+			},
+			expected: "struct Node is missing field Name. Add the missing field or exclude it",
+		},
+		{
+			name:   "text marshaler without errors",
+			cfg:    structConfig{Fields: []fieldConfig{regionField}},
+			target: []*types.Var{newField("Region", newTextType("example.com/org/project/core", "Region"))},
+			expected: "struct Node field Region uses x.UnmarshalText([]byte(_)) which returns an error. " +
+				"Set errors=true on struct Node, or on-error on the field.",
+		},
+		{
+			// A type without the text marshaling methods is not convertible.
+			name:     "text marshaler without methods",
+			cfg:      structConfig{Fields: []fieldConfig{regionField}},
+			target:   []*types.Var{newField("Region", newNamedStruct("example.com/org/project/core", "Zone"))},
+			expected: "struct Node field Region is not convertible to target",
+		},
+		{
+			name: "fallible func without errors",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName:      "Iden",
+				SourceExpr:      &ast.Ident{Name: "string"},
+				TargetName:      "ID",
+				SourceType:      types.Typ[types.String],
+				ConvertFuncTo:   convertFunc{Name: "IdenToCore", Errors: true},
+				ConvertFuncFrom: convertFunc{Name: "IdenFromCore", Errors: true},
+			}}},
+			target:   []*types.Var{newField("ID", types.Typ[types.String])},
+			expected: "struct Node field ID uses IdenToCore which returns an error. Set errors=true on struct Node.",
+		},
+		{
+			name: "ctx mismatch",
+			cfg: structConfig{
+				Ctx: newCtxType("context.Context"),
+				Fields: []fieldConfig{{
+					SourceName:      "Iden",
+					SourceExpr:      &ast.Ident{Name: "string"},
+					TargetName:      "ID",
+					SourceType:      types.Typ[types.String],
+					ConvertFuncTo:   convertFunc{Name: "IdenToCore", Ctx: newCtxType("*Options")},
+					ConvertFuncFrom: convertFunc{Name: "IdenFromCore", Ctx: newCtxType("*Options")},
+				}},
+			},
+			target:   []*types.Var{newField("ID", types.Typ[types.String])},
+			expected: "struct Node field ID uses IdenToCore which requires a ctx of type *Options.",
+		},
+		{
+			name: "skipped convert func",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName:      "Iden",
+				SourceExpr:      &ast.Ident{Name: "string"},
+				TargetName:      "ID",
+				SourceType:      types.Typ[types.String],
+				ConvertFuncTo:   convertFunc{Name: "IdenToCore", Skipped: true},
+				ConvertFuncFrom: convertFunc{Name: "IdenFromCore"},
+			}}},
+			target:   []*types.Var{newField("ID", types.Typ[types.String])},
+			expected: "struct Node field ID uses IdenToCore which is not generated.",
+		},
+		{
+			// The function is only called in the To direction, where the
+			// source is a pointer.
+			name: "nil pointer func",
+			cfg: structConfig{Fields: []fieldConfig{
+				weightField(newFunc(nil, "example.com/org/project/src", "DefaultWeight", nil, []types.Type{types.Typ[types.Int]})),
+			}},
+			target: []*types.Var{newField("Weight", types.Typ[types.Int])},
+		},
+		{
+			name: "nil pointer func with wrong result",
+			cfg: structConfig{Fields: []fieldConfig{
+				weightField(newFunc(nil, "example.com/org/project/src", "DefaultWeight", nil, []types.Type{types.Typ[types.String]})),
+			}},
+			target:   []*types.Var{newField("Weight", types.Typ[types.Int])},
+			expected: "struct Node field Weight uses nil-pointer=func:DefaultWeight which returns string, not int",
+		},
+		{
+			name: "nil pointer func with arguments",
+			cfg: structConfig{Fields: []fieldConfig{
+				weightField(newFunc(nil, "example.com/org/project/src", "DefaultWeight",
+					[]types.Type{types.Typ[types.Int]}, []types.Type{types.Typ[types.Int]})),
+			}},
+			target:   []*types.Var{newField("Weight", types.Typ[types.Int])},
+			expected: "struct Node field Weight uses nil-pointer=func:DefaultWeight which must take no arguments and return one value",
+		},
+		{
+			// An untyped constant can be assigned to the target.
+			name:   "default untyped constant",
+			cfg:    structConfig{Fields: []fieldConfig{timeoutField("30", types.Typ[types.UntypedInt])}},
+			target: []*types.Var{newField("Timeout", duration)},
+		},
+		{
+			// An untyped constant can be assigned when its value can be
+			// represented by the target, like it can in Go.
+			name: "default untyped float constant",
+			cfg: structConfig{Fields: []fieldConfig{func() fieldConfig {
+				f := timeoutField("1.0", types.Typ[types.UntypedFloat])
+				f.DefaultValue = constant.MakeFloat64(1)
+				return f
+			}()}},
+			target: []*types.Var{newField("Timeout", types.Typ[types.Int])},
+		},
+		{
+			name: "default untyped constant overflows",
+			cfg: structConfig{Fields: []fieldConfig{func() fieldConfig {
+				f := timeoutField("300", types.Typ[types.UntypedInt])
+				f.DefaultValue = constant.MakeInt64(300)
+				return f
+			}()}},
+			target:   []*types.Var{newField("Timeout", types.Typ[types.Uint8])},
+			expected: "struct Node field Timeout uses default=300 of type untyped int which can not be assigned to uint8",
+		},
+		{
+			name:     "default not assignable",
+			cfg:      structConfig{Fields: []fieldConfig{timeoutField("time.Second", duration)}},
+			target:   []*types.Var{newField("Timeout", types.Typ[types.Int])},
+			expected: "struct Node field Timeout uses default=time.Second of type time.Duration which can not be assigned to int",
+		},
+		{
+			// The default is only used in the To direction.
+			name: "default in the from direction",
+			cfg: structConfig{
+				Direction: DirFrom,
+				Fields:    []fieldConfig{timeoutField("time.Second", duration)},
+			},
+			target:   []*types.Var{newField("Timeout", duration)},
+			expected: "struct Node field Timeout uses default which is only assigned in the To direction",
+		},
+		{
+			name: "builtin without errors",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName: "Timeout",
+				SourceExpr: &ast.Ident{Name: "string"},
+				SourceType: types.Typ[types.String],
+			}}},
+			target:   []*types.Var{newField("Timeout", duration)},
+			expected: "uses time.ParseDuration(_) which returns an error",
+		},
+		{
+			name: "builtin with errors",
+			cfg: structConfig{
+				Errors: true,
+				Fields: []fieldConfig{{
+					SourceName: "Timeout",
+					SourceExpr: &ast.Ident{Name: "string"},
+					SourceType: types.Typ[types.String],
+				}},
+			},
+			target: []*types.Var{newField("Timeout", duration)},
+		},
+		{
+			// Functions found by signature are used in place of the builtin
+			// conversion, so they do not require errors=true.
+			name: "funcs before builtin",
+			cfg: structConfig{
+				Fields: []fieldConfig{{
+					SourceName: "Timeout",
+					SourceExpr: &ast.Ident{Name: "string"},
+					SourceType: types.Typ[types.String],
+				}},
+				Funcs: convertFuncs{
+					newConvertFuncKey(types.Typ[types.String], duration): {newFunc(nil, "example.com/org/project/src",
+						"parseTimeout", []types.Type{types.Typ[types.String]}, []types.Type{duration})},
+					newConvertFuncKey(duration, types.Typ[types.String]): {newFunc(nil, "example.com/org/project/src",
+						"formatTimeout", []types.Type{duration}, []types.Type{types.Typ[types.String]})},
+				},
+			},
+			target: []*types.Var{newField("Timeout", duration)},
+		},
+		{
+			name: "enum fallback error without errors",
+			cfg: structConfig{Fields: []fieldConfig{func() fieldConfig {
+				f := kindField(enumOrder, "Kind_B", "Kind_A")
+				f.EnumFallback = enumFallbackError
+				return f
+			}()}},
+			target:   []*types.Var{newField("Kind", coreKind)},
+			expected: "struct Node field Kind uses enum-fallback=error which returns an error. Set errors=true on struct Node.",
+		},
+		{
+			name:     "enum order with a different number of constants",
+			cfg:      structConfig{Fields: []fieldConfig{kindField(enumOrder, "Kind_A")}},
+			target:   []*types.Var{newField("Kind", coreKind)},
+			expected: "struct Node field Kind uses enum=order but example.com/org/project/core.Kind has 2 constants and example.com/org/project/src.Kind has 1",
+		},
+		{
+			name:   "lossy",
+			cfg:    structConfig{Fields: []fieldConfig{weightInt32Field("")}},
+			target: []*types.Var{newField("Weight", types.Typ[types.Int64])},
+			expected: "struct Node field Weight converts int64 to int32 in the From direction which is a narrowing conversion " +
+				"and may lose data. Set lossy=allow or lossy=check.",
+		},
+		{
+			name:     "lossy check without errors",
+			cfg:      structConfig{Fields: []fieldConfig{weightInt32Field(lossyCheck)}},
+			target:   []*types.Var{newField("Weight", types.Typ[types.Int64])},
+			expected: "struct Node field Weight uses lossy=check which returns an error. Set errors=true on struct Node.",
+		},
+		{
+			name:   "lossy allow",
+			cfg:    structConfig{Fields: []fieldConfig{weightInt32Field(lossyAllow)}},
+			target: []*types.Var{newField("Weight", types.Typ[types.Int64])},
+		},
+		{
+			// Widening conversions are always allowed.
+			name: "lossy in the widening direction",
+			cfg: structConfig{
+				Direction: DirTo,
+				Fields:    []fieldConfig{weightInt32Field("")},
+			},
+			target: []*types.Var{newField("Weight", types.Typ[types.Int64])},
+		},
+		{
+			name: "ambiguous funcs",
+			cfg: structConfig{
+				Fields: []fieldConfig{locationField},
+				Funcs: locationFuncs(newFunc(nil, "example.com/org/project/helpers", "LocationToCore",
+					[]types.Type{srcLocation}, []types.Type{coreLocation})),
+			},
+			target: []*types.Var{newField("Location", coreLocation)},
+			expected: "struct Node field Location can be converted from example.com/org/project/src.Location " +
+				"to example.com/org/project/core.Location by more than one function: " +
+				"example.com/org/project/helpers.LocationToCore, example.com/org/project/src.newCoreLocation. " +
+				"Set func-to or func-from to choose one.",
+		},
+		{
+			name:   "user func valid",
+			cfg:    structConfig{Fields: []fieldConfig{userFuncField(toCore, fromCore)}},
+			target: []*types.Var{newField("ID", coreID)},
+		},
+		{
+			name: "user func wrong param",
+			cfg: structConfig{Fields: []fieldConfig{userFuncField(
+				newFunc(nil, "example.com/org/project/src", "IDToCore", []types.Type{types.Typ[types.Int]}, []types.Type{coreID}),
+				fromCore)}},
+			target:   []*types.Var{newField("ID", coreID)},
+			expected: "struct Node field ID uses func-to=IDToCore which takes int, not string",
+		},
+		{
+			name: "user func wrong result",
+			cfg: structConfig{Fields: []fieldConfig{userFuncField(
+				toCore,
+				newFunc(nil, "example.com/org/project/src", "IDFromCore", []types.Type{coreID}, []types.Type{types.Typ[types.Int]}))}},
+			target:   []*types.Var{newField("ID", coreID)},
+			expected: "struct Node field ID uses func-from=IDFromCore which returns int, not string",
+		},
+		{
+			name:     "user func type conversion",
+			cfg:      structConfig{Fields: []fieldConfig{userFuncField(toCore, types.Universe.Lookup("uint"))}},
+			target:   []*types.Var{newField("ID", coreID)},
+			expected: "struct Node field ID uses func-from=uint which can not convert example.com/org/project/core.ID to uint",
+		},
+		{
+			name: "user func position",
+			cfg: structConfig{Fields: []fieldConfig{func() fieldConfig {
+				f := userFuncField(toCore, toCore)
+				f.Pos = token.Position{Filename: "node.go", Line: 12, Column: 2}
+				return f
+			}()}},
+			target: []*types.Var{newField("ID", coreID)},
+			expected: "node.go:12:2: struct Node field ID uses func-from=IDToCore which takes string, " +
+				"not example.com/org/project/core.ID",
+		},
+		{
+			name: "user func ctx",
+			cfg: structConfig{
+				Ctx:    newCtxType("*Options"),
+				Fields: []fieldConfig{userFuncField(ctxToCore, ctxFromCore)},
+			},
+			target: []*types.Var{newField("ID", coreID)},
+		},
+		{
+			name: "user func ctx with package",
+			cfg: structConfig{
+				Ctx:    newCtxType("*example.com/org/project/src.Options"),
+				Fields: []fieldConfig{userFuncField(ctxToCore, ctxFromCore)},
+			},
+			target: []*types.Var{newField("ID", coreID)},
+		},
+		{
+			name: "user func wrong ctx",
+			cfg: structConfig{
+				Ctx:    newCtxType("context.Context"),
+				Fields: []fieldConfig{userFuncField(ctxToCore, ctxFromCore)},
+			},
+			target: []*types.Var{newField("ID", coreID)},
+			expected: "struct Node field ID uses func-to=IDToCore which takes a ctx of type " +
+				"*example.com/org/project/src.Options, not context.Context",
+		},
+		{
+			name: "user func missing func-from",
+			cfg: structConfig{Fields: []fieldConfig{func() fieldConfig {
+				f := userFuncField(toCore, fromCore)
+				f.FuncFrom = ""
+				return f
+			}()}},
+			target:   []*types.Var{newField("ID", coreID)},
+			expected: "struct Node field ID uses func-to without func-from. Set both, or set direction=to on the field.",
+		},
+		{
+			name: "user func missing func-from with direction",
+			cfg: structConfig{Fields: []fieldConfig{func() fieldConfig {
+				f := userFuncField(toCore, fromCore)
+				f.FuncFrom = ""
+				f.Direction = DirTo
+				return f
+			}()}},
+			target: []*types.Var{newField("ID", coreID)},
+		},
+		{
+			name: "user func missing elem-func-to",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName:   "ID",
+				SourceExpr:   &ast.Ident{Name: "string"},
+				SourceType:   types.Typ[types.String],
+				ElemFuncFrom: "IDFromCore",
+			}}},
+			target:   []*types.Var{newField("ID", coreID)},
+			expected: "struct Node field ID uses elem-func-from without elem-func-to. Set both, or set direction=from on the field.",
+		},
+		{
+			name:   "integer to string enum",
+			cfg:    structConfig{Fields: []fieldConfig{kindInt32Field}},
+			target: []*types.Var{newField("Kind", serviceKind)},
+			expected: "struct Node field Kind is not convertible to target: a conversion from int32 to " +
+				"example.com/org/project/core.ServiceKind would convert integers to runes, not their digits. " +
+				"Set enum=order or enum=name to convert the constants of an enum, or set func-to and func-from.",
+		},
+		{
+			// Go can convert an integer to a string, but not its digits.
+			name: "integer to string in one direction",
+			cfg: structConfig{
+				Direction: DirTo,
+				Fields:    []fieldConfig{kindInt32Field},
+			},
+			target:   []*types.Var{newField("Kind", types.Typ[types.String])},
+			expected: "struct Node field Kind is not convertible to target: a conversion from int32 to string would convert integers to runes",
+		},
+		{
+			name: "integer to string elements",
+			cfg: structConfig{Fields: []fieldConfig{{
+				SourceName: "Kinds",
+				SourceExpr: &ast.ArrayType{Elt: &ast.Ident{Name: "int32"}},
+				SourceType: types.NewSlice(types.Typ[types.Int32]),
+			}}},
+			target:   []*types.Var{newField("Kinds", types.NewSlice(types.Typ[types.String]))},
+			expected: "struct Node field Kinds is not convertible to target: a conversion from int32 to string would convert integers to runes",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func newNamedStruct(pkgPath string, name string) *types.Named {
+	pkg := types.NewPackage(pkgPath, path.Base(pkgPath))
+	return types.NewNamed(types.NewTypeName(0, pkg, name, nil), &types.Struct{}, nil)
+}
+
+// duration is the time.Duration type, declared without loading the time
+// package.
+var duration = types.NewNamed(
+	types.NewTypeName(0, types.NewPackage("time", "time"), "Duration", nil),
+	types.Typ[types.Int64],
+	nil)
+
+// timeoutField returns a time.Duration field with the default value, of the
+// type.
+func timeoutField(value string, typ types.Type) fieldConfig {
+	return fieldConfig{
+		SourceName:     "Timeout",
+		SourceExpr:     &ast.SelectorExpr{X: &ast.Ident{Name: "time"}, Sel: &ast.Ident{Name: "Duration"}},
+		SourceType:     duration,
+		Default:        value,
+		DefaultType:    typ,
+		DefaultImports: map[string]string{"time": "time"},
+	}
+}
+
+// newEnum returns a named type with constants declared in the order of
+// names, with the values 0, 1, 2, etc.
+func newEnum(pkgPath string, name string, names ...string) *types.Named {
+	pkg := types.NewPackage(pkgPath, path.Base(pkgPath))
+	typ := types.NewNamed(types.NewTypeName(0, pkg, name, nil), types.Typ[types.Int32], nil)
+	for i, n := range names {
+		c := types.NewConst(token.Pos(i+1), pkg, n, typ, constant.MakeInt64(int64(i)))
+		pkg.Scope().Insert(c)
+	}
+	return typ
+}
+
+// coreKind is an enum of the core package with an unexported constant.
+var coreKind = newEnum("example.com/org/project/core", "Kind", "KindB", "KindA", "kindUnknown")
+
+// kindField returns a field of an enum of the source package with the
+// constants, in the order they are declared, which is converted using mode.
+func kindField(mode enumMode, names ...string) fieldConfig {
+	return fieldConfig{
+		SourceName: "Kind",
+		SourceExpr: &ast.Ident{Name: "Kind"},
+		SourceType: newEnum("example.com/org/project/src", "Kind", names...),
+		Enum:       mode,
+	}
+}
+
+func TestComputeEnumPairs_Name(t *testing.T) {
+	left := newEnum("example.com/org/project/core", "ServiceKind",
+		"ServiceKindTypical", "ServiceKindMeshGateway", "ServiceKindIngress")
+	right := newEnum("example.com/org/project/api", "ServiceKind",
+		"SERVICE_KIND_TYPICAL", "ServiceKind_MeshGateway", "ServiceKind_IngressGateway")
+
+	_, err := computeEnumPairs(enumName, left, right, nil, "")
+	expected := "uses enum=name but constants ServiceKind_IngressGateway of example.com/org/project/api.ServiceKind, " +
+		"ServiceKindIngress of example.com/org/project/core.ServiceKind have no match. Pair them using enum-map."
+	assert.ErrorContains(t, err, expected)
+
+	overrides := map[string]string{"ServiceKind_IngressGateway": "ServiceKindIngress"}
+	pairs, err := computeEnumPairs(enumName, left, right, overrides, "")
+	assert.NilError(t, err)
+	var names [][2]string
+	for _, pair := range pairs {
+		names = append(names, [2]string{pair.Right.Name(), pair.Left.Name()})
+	}
+	assert.DeepEqual(t, names, [][2]string{
+		{"SERVICE_KIND_TYPICAL", "ServiceKindTypical"},
+		{"ServiceKind_MeshGateway", "ServiceKindMeshGateway"},
+		{"ServiceKind_IngressGateway", "ServiceKindIngress"},
+	})
+
+	overrides = map[string]string{"ServiceKind_IngressGateway": "ServiceKindIngres"}
+	_, err = computeEnumPairs(enumName, left, right, overrides, "")
+	assert.ErrorContains(t, err, "uses enum-map with unknown constant ServiceKindIngres of example.com/org/project/core.ServiceKind")
+
+	_, err = computeEnumPairs(enumOrder, left, right, overrides, "")
+	assert.ErrorContains(t, err, "uses enum-map which can only be used with enum=name")
+}
+
+func TestNumericConversion_Class(t *testing.T) {
+	cases := []struct {
+		to, from types.BasicKind
+		expected numericClass
+	}{
+		{to: types.Int64, from: types.Int32, expected: numericWidening},
+		{to: types.Int, from: types.Int64, expected: numericWidening},
+		{to: types.Int64, from: types.Uint32, expected: numericWidening},
+		{to: types.Float64, from: types.Float32, expected: numericWidening},
+		{to: types.Float64, from: types.Int32, expected: numericWidening},
+		{to: types.Int32, from: types.Int64, expected: numericNarrowing},
+		{to: types.Uint16, from: types.Int32, expected: numericNarrowing},
+		{to: types.Float32, from: types.Float64, expected: numericNarrowing},
+		{to: types.Uint64, from: types.Int, expected: numericSignChange},
+		{to: types.Int32, from: types.Uint32, expected: numericSignChange},
+		{to: types.Int, from: types.Float64, expected: numericFloatToInt},
+		{to: types.Float64, from: types.Int64, expected: numericIntToFloat},
+		{to: types.Float32, from: types.Int32, expected: numericIntToFloat},
+	}
+	for _, tc := range cases {
+		conv, ok := newNumericConversion(types.Typ[tc.to], types.Typ[tc.from])
+		assert.Assert(t, ok)
+		assert.Equal(t, conv.Class(), tc.expected, conv.String())
+	}
+
+	_, ok := newNumericConversion(types.Typ[types.String], types.Typ[types.Int])
+	assert.Assert(t, !ok)
+}
+
+func TestComputeAssignment_Directions(t *testing.T) {
+	anyType := types.Universe.Lookup("any").Type()
+	str := types.Typ[types.String]
+
+	// A string can be assigned to any, but not the other way around.
+	kind, ok := computeAssignment(anyType, str, []Direction{DirTo})
+	assert.Assert(t, ok)
+	assert.Assert(t, kind.(*singleAssignmentKind).Direct)
+
+	_, ok = computeAssignment(anyType, str, []Direction{DirFrom})
+	assert.Assert(t, !ok)
+	_, ok = computeAssignment(anyType, str, []Direction{DirTo, DirFrom})
+	assert.Assert(t, !ok)
+
+	// The elements are checked in the same directions.
+	kind, ok = computeAssignment(types.NewSlice(anyType), types.NewSlice(str), []Direction{DirTo})
+	assert.Assert(t, ok)
+	assert.Assert(t, kind.(*sliceAssignmentKind).ElemDirect)
+
+	_, ok = computeAssignment(types.NewSlice(anyType), types.NewSlice(str), []Direction{DirTo, DirFrom})
+	assert.Assert(t, !ok)
+}
+
+func TestTypeToExpr_Alias(t *testing.T) {
+	imports := newImports()
+	imports.local = "example.com/org/project/src"
+
+	value := newNamedStruct("encoding/json/jsontext", "Value")
+	rawMessage := types.NewAlias(
+		types.NewTypeName(0, types.NewPackage("encoding/json", "json"), "RawMessage", nil),
+		value)
+	assert.Equal(t, types.ExprString(typeToExpr(rawMessage, imports, false)), "json.RawMessage")
+
+	// Aliases in the universe scope have no package.
+	anyType := types.Universe.Lookup("any").Type()
+	assert.Equal(t, types.ExprString(typeToExpr(anyType, imports, false)), "any")
+}
+
+func TestNewIfElseNilPolicy(t *testing.T) {
+	format := func(leftType ast.Expr) string {
+		stmt := newIfElseNilPolicy(assignOptions{}, &ast.Ident{Name: "ok"}, &ast.Ident{Name: "v"}, leftType)
+		buf := new(bytes.Buffer)
+		assert.NilError(t, printer.Fprint(buf, token.NewFileSet(), stmt.(*ast.IfStmt).Else))
+		return buf.String()
+	}
+
+	slice := &ast.ArrayType{Elt: &ast.Ident{Name: "byte"}}
+	assert.Equal(t, format(slice), "{\n\tv = nil\n}")
+
+	// Arrays can not be nil, so they are assigned the zero value.
+	array := &ast.ArrayType{Len: &ast.BasicLit{Kind: token.INT, Value: "4"}, Elt: &ast.Ident{Name: "byte"}}
+	assert.Equal(t, format(array), "{\n\tvar x [4]byte\n\tv = x\n}")
+}
+
+func TestLookupBuiltinConversion_Bytes(t *testing.T) {
+	uint8s := types.NewSlice(types.Typ[types.Uint8])
+	conv, ok := lookupBuiltinConversion(uint8s, types.Typ[types.String])
+	assert.Assert(t, ok)
+	assert.Equal(t, conv.For(DirTo).Expr, "[]byte(_)")
+	assert.Equal(t, conv.For(DirFrom).Expr, "string(_)")
+
+	rawMessage := types.NewNamed(
+		types.NewTypeName(0, types.NewPackage("encoding/json", "json"), "RawMessage", nil),
+		types.NewSlice(types.Typ[types.Byte]), nil)
+
+	// json.RawMessage is assignable to []byte, but is copied.
+	kind, ok := computeAssignment(rawMessage, uint8s, []Direction{DirTo, DirFrom})
+	assert.Assert(t, ok)
+	single, ok := kind.(*singleAssignmentKind)
+	assert.Assert(t, ok)
+	assert.Assert(t, !single.Direct)
+	assert.Equal(t, single.Builtin.For(DirTo).Expr, "bytes.Clone(_)")
+}
+
+// builtinTypeExpr returns the Go expression for the type, which is written as
+// it is in builtinPairs, and the path of the package it refers to, if any.
+func builtinTypeExpr(typ string) (string, string) {
+	var prefix string
+	if strings.HasPrefix(typ, "*") {
+		prefix, typ = "*", typ[1:]
+	}
+	i := strings.LastIndex(typ, ".")
+	if i == -1 {
+		return prefix + typ, ""
+	}
+	pkgPath := typ[:i]
+	return prefix + path.Base(pkgPath) + typ[i:], pkgPath
+}
+
+func TestGenerateConversion_BuiltinPairs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type checking the standard library is too slow for -short")
+	}
+
+	fset := token.NewFileSet()
+	std := importer.ForCompiler(fset, "source", nil)
+	typeCheck := func(t *testing.T, pkgPath string, imp types.Importer, sources ...string) *types.Package {
+		t.Helper()
+		var files []*ast.File
+		for i, src := range sources {
+			file, err := parser.ParseFile(fset, fmt.Sprintf("%v/%d.go", pkgPath, i), src, 0)
+			assert.NilError(t, err, src)
+			files = append(files, file)
+		}
+		pkg, err := (&types.Config{Importer: imp}).Check(pkgPath, fset, files, nil)
+		assert.NilError(t, err, strings.Join(sources, "\n"))
+		return pkg
+	}
+	// structSource returns the source of a package which declares a Node
+	// struct with a Value field of the type.
+	structSource := func(name string, typ string) string {
+		expr, pkgPath := builtinTypeExpr(typ)
+		src := "package " + name + "\n\n"
+		if pkgPath != "" {
+			src += "import " + strconv.Quote(pkgPath) + "\n\n"
+		}
+		return src + "type Node struct {\n\tValue " + expr + "\n}\n"
+	}
+
+	run := func(t *testing.T, sourceType, targetType string) {
+		core := typeCheck(t, "example.com/org/project/core", std, structSource("core", targetType))
+		imp := importerFunc(func(pkgPath string) (*types.Package, error) {
+			if pkgPath == core.Path() {
+				return core, nil
+			}
+			return std.Import(pkgPath)
+		})
+
+		nodeSource := structSource("src", sourceType)
+		src := typeCheck(t, "example.com/org/project/src", imp, nodeSource)
+		sourceExpr, _ := builtinTypeExpr(sourceType)
+		expr, err := parser.ParseExpr(sourceExpr)
+		assert.NilError(t, err)
+
+		c := structConfig{
+			Source:           "Node",
+			FuncNameFragment: "Core",
+			Target: target{
+				Package: core.Path(),
+				Struct:  "Node",
+			},
+			Errors: true,
+			Fields: []fieldConfig{{
+				SourceName: "Value",
+				SourceExpr: expr,
+				SourceType: src.Scope().Lookup("Node").Type().Underlying().(*types.Struct).Field(0).Type(),
+			}},
+		}
+		target := targetStruct{
+			Name:   "Node",
+			Fields: []*types.Var{core.Scope().Lookup("Node").Type().Underlying().(*types.Struct).Field(0)},
+		}
+		imports := newImports()
+		imports.local = src.Path()
+		gen, err := generateConversion(c, target, imports)
+		assert.NilError(t, err)
+
+		decls := []ast.Decl{gen.To, gen.From}
+		imports.RemoveUnused(decls)
+		file := &ast.File{Name: &ast.Ident{Name: "src"}}
+		file.Decls = append([]ast.Decl{imports.Decl()}, decls...)
+		out, err := astToBytes(&token.FileSet{}, file)
+		assert.NilError(t, err)
+
+		typeCheck(t, src.Path(), imp, nodeSource, string(out))
+	}
+
+	for _, pair := range builtinPairs {
+		t.Run(pair.B+" to "+pair.A, func(t *testing.T) {
+			run(t, pair.B, pair.A)
+		})
+		t.Run(pair.A+" to "+pair.B, func(t *testing.T) {
+			run(t, pair.A, pair.B)
+		})
+	}
+}
+
+// importerFunc implements types.Importer with a function.
+type importerFunc func(pkgPath string) (*types.Package, error)
+
+func (f importerFunc) Import(pkgPath string) (*types.Package, error) {
+	return f(pkgPath)
+}
+
+// newFunc returns a function of the package, or a method if recv is not nil,
+// which takes the params and returns the results.
+func newFunc(recv types.Type, pkgPath string, name string, params, results []types.Type) *types.Func {
+	pkg := types.NewPackage(pkgPath, path.Base(pkgPath))
+	tuple := func(typs []types.Type) *types.Tuple {
+		vars := make([]*types.Var, 0, len(typs))
+		for _, typ := range typs {
+			vars = append(vars, types.NewParam(0, pkg, "", typ))
+		}
+		return types.NewTuple(vars...)
+	}
+	var recvVar *types.Var
+	if recv != nil {
+		recvVar = types.NewParam(0, pkg, "", recv)
+	}
+	sig := types.NewSignatureType(recvVar, nil, nil, tuple(params), tuple(results), false)
+	return types.NewFunc(0, pkg, name, sig)
+}
+
+// newTextType returns a named struct type with a MarshalText method on the
+// value and an UnmarshalText method on the pointer.
+func newTextType(pkgPath string, name string) *types.Named {
+	typ := newNamedStruct(pkgPath, name)
+	bytes := types.NewSlice(types.Typ[types.Byte])
+	errType := types.Universe.Lookup("error").Type()
+	typ.AddMethod(newFunc(typ, pkgPath, "MarshalText", nil, []types.Type{bytes, errType}))
+	typ.AddMethod(newFunc(types.NewPointer(typ), pkgPath, "UnmarshalText", []types.Type{bytes}, []types.Type{errType}))
+	return typ
+}
+
+func TestApplyAutoConvertFunctions_Methods(t *testing.T) {
+	apiRack := newNamedStruct("example.com/org/project/api", "Rack")
+	coreRack := newNamedStruct("example.com/org/project/core", "Rack")
+	errType := types.Universe.Lookup("error").Type()
+	apiRack.AddMethod(newFunc(apiRack, "example.com/org/project/api", "ToCore", nil, []types.Type{coreRack}))
+	coreRack.AddMethod(newFunc(coreRack, "example.com/org/project/core", "ToAPI", nil, []types.Type{apiRack, errType}))
+
 	c := structConfig{
 		Source:           "Node",
 		FuncNameFragment: "Core",
@@ -67,37 +1073,86 @@ func TestGenerateConversion(t *testing.T) {
 			Package: "example.com/org/project/core",
 			Struct:  "Node",
 		},
-		Fields: []fieldConfig{{
-			SourceName: "Iden",
-			SourceExpr: &ast.Ident{Name: "string"},
-			TargetName: "ID",
-			SourceType: types.Typ[types.String],
+		Fields: []fieldConfig{
+			{SourceName: "Rack", SourceType: apiRack},
+			{SourceName: "Racks", SourceType: types.NewSlice(apiRack)},
+			{SourceName: "ByName", SourceType: types.NewMap(types.Typ[types.String], apiRack)},
+			{SourceName: "Other", SourceType: types.Typ[types.String]},
+		},
+	}
+	targets := map[string]targetPkg{
+		"example.com/org/project/core": {Structs: map[string]targetStruct{
+			"Node": {Name: "Node", Fields: []*types.Var{
+				newField("Rack", coreRack),
+				newField("Racks", types.NewSlice(coreRack)),
+				newField("ByName", types.NewMap(types.Typ[types.String], coreRack)),
+				newField("Other", types.Typ[types.String]),
+			}},
 		}},
 	}
-	target := targetStruct{
-		Fields: []*types.Var{
-			newField("ID", types.Typ[types.String]),
-		},
+
+	// The method names are empty by default, so methods are not used unless
+	// they are named by the struct annotation.
+	cfgs := applyAutoConvertFunctions([]structConfig{c}, targets)
+	for _, f := range cfgs[0].Fields {
+		assert.Assert(t, !f.hasAutoFuncs())
 	}
-	imports := newImports()
-	gen, err := generateConversion(c, target, imports)
-	assert.NilError(t, err)
 
-	file := &ast.File{Name: &ast.Ident{Name: "src"}}
-	file.Decls = append(file.Decls, imports.Decl())
-	file.Decls = append(file.Decls, gen.To, gen.From)
+	// Methods which only convert in one direction are not used.
+	c.MethodTo = "ToCore"
+	cfgs = applyAutoConvertFunctions([]structConfig{c}, targets)
+	for _, f := range cfgs[0].Fields {
+		assert.Assert(t, !f.hasAutoFuncs())
+	}
 
-	out, err := astToBytes(&token.FileSet{}, file)
-	assert.NilError(t, err)
+	c.MethodFrom = "ToAPI"
+	cfgs = applyAutoConvertFunctions([]structConfig{c}, targets)
+	fields := cfgs[0].Fields
+
+	expectedTo := valueFunc{Name: "ToCore", Method: true}
+	expectedFrom := valueFunc{Name: "ToAPI", Errors: true, Method: true}
+	assert.Equal(t, fields[0].UserFunc(DirTo), expectedTo)
+	assert.Equal(t, fields[0].UserFunc(DirFrom), expectedFrom)
+	for _, f := range fields[1:3] {
+		assert.Assert(t, f.AutoElemFuncs)
+		assert.Equal(t, f.UserElemFunc(DirTo), expectedTo)
+		assert.Equal(t, f.UserElemFunc(DirFrom), expectedFrom)
+	}
+	assert.Assert(t, !fields[3].hasAutoFuncs())
+
+	// Enums are converted by their constants instead.
+	c.Fields[0] = fieldConfig{SourceName: "Rack", SourceType: apiRack, Enum: enumName}
+	cfgs = applyAutoConvertFunctions([]structConfig{c}, targets)
+	assert.Assert(t, !cfgs[0].Fields[0].hasAutoFuncs())
+}
+
+var (
+	srcLocation  = newNamedStruct("example.com/org/project/src", "Location")
+	coreLocation = newNamedStruct("example.com/org/project/core", "Location")
+)
 
-	if *shouldPrint {
-		t.Logf("OUTPUT\n%s\n", PrependLineNumbers(string(out)))
+// locationFuncs returns the functions which convert between srcLocation and
+// coreLocation, found in the source and helper packages, and the other
+// functions.
+func locationFuncs(other ...*types.Func) convertFuncs {
+	funcs := convertFuncs{}
+	funcs.Add(newFunc(nil, "example.com/org/project/src", "newCoreLocation",
+		[]types.Type{srcLocation}, []types.Type{coreLocation}))
+	funcs.Add(newFunc(nil, "example.com/org/project/helpers", "LocationFromCore",
+		[]types.Type{coreLocation}, []types.Type{srcLocation}))
+	for _, fn := range other {
+		funcs.Add(fn)
 	}
-	golden.Assert(t, string(out), t.Name()+"-expected")
-	// TODO: check gen.RoundTripTest
+	return funcs
+}
+
+var locationField = fieldConfig{
+	SourceName: "Location",
+	SourceExpr: &ast.Ident{Name: "Location"},
+	SourceType: srcLocation,
 }
 
-func TestGenerateConversion_WithMissingSourceField(t *testing.T) {
+func TestTypeErrorFor(t *testing.T) {
 	c := structConfig{
 		Source:           "Node",
 		FuncNameFragment: "Core",
@@ -110,18 +1165,120 @@ func TestGenerateConversion_WithMissingSourceField(t *testing.T) {
 			SourceExpr: &ast.Ident{Name: "string"},
 			TargetName: "ID",
 			SourceType: types.Typ[types.String],
+			Pos:        token.Position{Filename: "node.go", Line: 12, Column: 2},
 		}},
 	}
 	target := targetStruct{
 		Fields: []*types.Var{
 			newField("ID", types.Typ[types.String]),
-			newField("Name", types.Typ[types.String]),
 		},
 	}
 	imports := newImports()
-	_, err := generateConversion(c, target, imports)
-	expected := "struct Node is missing field Name. Add the missing field or exclude it"
-	assert.ErrorContains(t, err, expected)
+	gen, err := generateConversion(c, target, imports)
+	assert.NilError(t, err)
+
+	file := &ast.File{Name: &ast.Ident{Name: "src"}}
+	file.Decls = append(file.Decls, imports.Decl(), gen.To, gen.From)
+	out, err := astToBytes(&token.FileSet{}, file)
+	assert.NilError(t, err)
+
+	generated := generatedFile{
+		Path:     "node_gen.go",
+		Contents: append([]byte(generatedHeader), out...),
+		Structs:  []structConfig{c, c},
+	}
+	lineOf := func(text string) int {
+		for i, line := range bytes.Split(generated.Contents, []byte("\n")) {
+			if bytes.Contains(line, []byte(text)) {
+				return i + 1
+			}
+		}
+		t.Fatalf("generated code does not contain %q", text)
+		return 0
+	}
+
+	pos := token.Position{Filename: "node_gen.go", Line: lineOf("s.Iden = t.ID"), Column: 2}
+	err = typeErrorFor(generated, pos, "cannot use t.ID")
+	expected := "node.go:12:2: struct Node field ID does not compile: cannot use t.ID (node_gen.go:" +
+		strconv.Itoa(pos.Line) + ":2)"
+	assert.Error(t, err, expected)
+
+	pos = token.Position{Filename: "node_gen.go", Line: lineOf("func NodeToCore"), Column: 1}
+	err = typeErrorFor(generated, pos, "undefined: Node")
+	assert.Error(t, err, "node_gen.go:"+strconv.Itoa(pos.Line)+":1: struct Node does not compile: undefined: Node")
+
+	pos = token.Position{Filename: "node_gen.go", Line: 1, Column: 1}
+	err = typeErrorFor(generated, pos, "other")
+	assert.Error(t, err, "node_gen.go:1:1: other")
+}
+
+func TestCheckOverwrite(t *testing.T) {
+	dir := t.TempDir()
+
+	output := filepath.Join(dir, "node_gen.go")
+	assert.NilError(t, checkOverwrite(output))
+
+	assert.NilError(t, os.WriteFile(output, []byte(generatedHeader+"package src\n"), 0644))
+	assert.NilError(t, checkOverwrite(output))
+
+	output = filepath.Join(dir, "node.go")
+	assert.NilError(t, os.WriteFile(output, []byte("package src\n"), 0644))
+	expected := output + " was not generated by mog. Change the output annotation, or use -force to overwrite it."
+	assert.Error(t, checkOverwrite(output), expected)
+}
+
+func TestOrphanedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, contents string) string {
+		filename := filepath.Join(dir, name)
+		assert.NilError(t, os.WriteFile(filename, []byte(contents), 0644))
+		return filename
+	}
+	node := write("node.go", "package src\n")
+	orphan := write("old_gen.go", generatedHeader+"package src\n")
+	output := write("node_gen.go", generatedHeader+"package src\n")
+	tagged := write("tagged_gen.go", generatedHeader+"//go:build other\n\npackage src\n")
+	write("notes.txt", generatedHeader)
+	assert.NilError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	p := sourcePkg{
+		Path: dir,
+		Name: "src",
+		pkg:  &packages.Package{GoFiles: []string{node, orphan, output}},
+	}
+	files := []generatedFile{{Path: output}}
+	orphans, excluded, err := orphanedFiles(p, files)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, orphans, []string{orphan})
+	// The file may be generated by a run with the other build tag.
+	assert.DeepEqual(t, excluded, []string{tagged})
+
+	// Other files may be generated from the files which do not match the glob.
+	p.Glob = "*_api.go"
+	orphans, excluded, err = orphanedFiles(p, files)
+	assert.NilError(t, err)
+	assert.Equal(t, len(orphans), 0)
+	assert.Equal(t, len(excluded), 0)
+}
+
+func TestGeneratedFileStatus(t *testing.T) {
+	file := generatedFile{
+		Path:     filepath.Join(t.TempDir(), "node_gen.go"),
+		Contents: []byte(generatedHeader + "package src\n"),
+	}
+	status, err := generatedFileStatus(file)
+	assert.NilError(t, err)
+	assert.Equal(t, status, fileCreated)
+
+	assert.NilError(t, os.WriteFile(file.Path, file.Contents, 0644))
+	status, err = generatedFileStatus(file)
+	assert.NilError(t, err)
+	assert.Equal(t, status, fileUnchanged)
+
+	file.Contents = append(file.Contents, "\nfunc f() {}\n"...)
+	status, err = generatedFileStatus(file)
+	assert.NilError(t, err)
+	assert.Equal(t, status, fileUpdated)
 }
 
 func TestImports(t *testing.T) {
@@ -168,3 +1325,38 @@ func TestImports(t *testing.T) {
 		golden.Assert(t, string(out), "TestImports-Decls-expected")
 	})
 }
+
+func TestImports_RemoveUnused(t *testing.T) {
+	src := `package src
+
+func NodeToCore(s *Node, t *core.Node) {
+	t.Name = s.Name
+	for i, x := range s.Items {
+		t.Items[i] = x.ID
+	}
+	var r time.Time
+	t.Created = r.Unix()
+}
+`
+	file, err := parser.ParseFile(token.NewFileSet(), "node_gen.go", src, 0)
+	assert.NilError(t, err)
+
+	imp := newImports()
+	for _, pkgPath := range []string{
+		"example.com/org/project/core",
+		"example.com/org/project/r",
+		"example.com/org/project/s",
+		"example.com/org/project/x",
+		"time",
+	} {
+		imp.Add("", pkgPath)
+	}
+	imp.RemoveUnused(file.Decls)
+
+	// The packages named like the parameters and local variables are not used.
+	expected := map[string]string{
+		"example.com/org/project/core": "core",
+		"time":                         "time",
+	}
+	assert.DeepEqual(t, imp.byPkgPath, expected)
+}
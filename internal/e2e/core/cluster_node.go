@@ -3,10 +3,22 @@
 
 package core
 
-import "github.com/hashicorp/mog/internal/e2e/core/inner"
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/mog/internal/e2e/core/inner"
+)
 
 type Label string
 
+const DefaultPartition = "default"
+
 type ClusterNode struct {
 	ID    string
 	Label Label
@@ -50,6 +62,18 @@ type ClusterNode struct {
 	M7 map[string]Workload
 	M8 map[string]*Workload
 
+	E1 []int
+	E2 map[string]Label
+
+	CreateIndex uint64
+
+	P1 Workload
+	P2 int64
+
+	Timeout   int
+	Partition string
+	Grace     time.Duration
+
 	// S1 Workload  // for testing struct-to-struct for slices
 	// S2 *Workload // for testing ptr-to-ptr for slices
 	// S3 Workload  // for testing ptr-to-struct for slices
@@ -68,3 +92,141 @@ type Workload struct {
 type Other struct {
 	N int
 }
+
+type Service struct {
+	Name      string
+	Namespace string
+
+	Primary   Endpoint
+	Endpoints []Endpoint
+	ByName    map[string]*Endpoint
+
+	Ports []int
+
+	Kind ServiceKind
+}
+
+type ServiceKind string
+
+const (
+	ServiceKindTypical      ServiceKind = ""
+	ServiceKindConnectProxy ServiceKind = "connect-proxy"
+	ServiceKindMeshGateway  ServiceKind = "mesh-gateway"
+)
+
+type Endpoint struct {
+	Address  net.IP
+	Protocol Protocol
+}
+
+type Protocol string
+
+const (
+	ProtocolTCP Protocol = "tcp"
+	ProtocolUDP Protocol = "udp"
+)
+
+type Status struct {
+	Healthy bool
+	Reason  Label
+}
+
+type Snapshot struct {
+	Tags    []string
+	Meta    map[string]interface{}
+	Owner   *Other
+	Weights []*int
+	Count   *int
+	Shared  []string
+	Labels  map[string]string
+}
+
+type Schedule struct {
+	Timeout   time.Duration
+	Interval  int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Expires   string
+	Address   net.IP
+	Endpoint  *url.URL
+	Homepage  url.URL
+	Retries   []time.Duration
+}
+
+type Placement struct {
+	Rack  Rack
+	Racks []Rack
+	Zone  Zone
+	Zones map[string]Zone
+}
+
+type Rack struct {
+	ID string
+}
+
+// Zone is a zone in the form <region>/<name>.
+type Zone string
+
+func (z Zone) ToInner() (inner.Zone, error) {
+	region, name, ok := strings.Cut(string(z), "/")
+	if !ok {
+		return inner.Zone{}, fmt.Errorf("invalid zone %q", z)
+	}
+	return inner.Zone{Region: region, Name: name}, nil
+}
+
+type Network struct {
+	Subnet  netip.Prefix
+	Gateway netip.Addr
+	Peers   []netip.Addr
+	Backup  *netip.Addr
+	Region  Region
+}
+
+// Region is a text type with the name and zone of a region.
+type Region struct {
+	Name string
+	Zone string
+}
+
+func (r Region) MarshalText() ([]byte, error) {
+	return []byte(r.Name + "/" + r.Zone), nil
+}
+
+func (r *Region) UnmarshalText(text []byte) error {
+	name, zone, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("invalid region %q", text)
+	}
+	r.Name, r.Zone = name, zone
+	return nil
+}
+
+type Payload struct {
+	Value  string
+	Raw    json.RawMessage
+	Config []byte
+	Chunks [][]byte
+	Values map[string]string
+}
+
+type Limits struct {
+	MaxConns int64
+	Attempts int32
+	Port     uint16
+	Ratio    float32
+	Offsets  []uint64
+}
+
+type Site struct {
+	Coordinates Coordinates
+	Tag         Tag
+}
+
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Tag is a tag in the form <key>=<value>.
+type Tag string
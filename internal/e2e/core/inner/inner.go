@@ -6,3 +6,13 @@ package inner
 type Inner struct {
 	M string
 }
+
+type Zone struct {
+	Region string
+	Name   string
+}
+
+type Tag struct {
+	Key   string
+	Value string
+}
@@ -0,0 +1,27 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+// Package helpers has conversion functions which are found by signature when
+// it is passed to -helper-packages.
+package helpers
+
+import (
+	"strings"
+
+	"github.com/hashicorp/mog/internal/e2e/core"
+	"github.com/hashicorp/mog/internal/e2e/core/inner"
+)
+
+func FormatTag(t inner.Tag) core.Tag {
+	return core.Tag(t.Key + "=" + t.Value)
+}
+
+func ParseTag(t core.Tag) inner.Tag {
+	key, value, _ := strings.Cut(string(t), "=")
+	return inner.Tag{Key: key, Value: value}
+}
+
+// formatTag is not exported, so it is not found.
+func formatTag(t inner.Tag) core.Tag {
+	return core.Tag(t.Key + ":" + t.Value)
+}
@@ -0,0 +1,29 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package sourcepkg
+
+// Limits source structure for testing numeric conversions which may lose data.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Limits
+// output=node_gen.go
+// errors=true
+type Limits struct {
+	// mog: direction=to
+	MaxConns int32 // for testing widening conversions, which are not checked
+
+	// mog: lossy=check
+	Attempts int64 // for testing narrowing to int32
+
+	// mog: lossy=check
+	Port int32 // for testing narrowing and sign change to uint16
+
+	// mog: lossy=allow
+	Ratio float64 // for testing float64 to float32 without a check
+
+	// mog: lossy=check
+	Offsets []int // for testing sign change of slice elements to uint64
+}
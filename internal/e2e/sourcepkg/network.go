@@ -0,0 +1,25 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package sourcepkg
+
+// Network source structure for testing conversions of types which implement
+// encoding.TextMarshaler and encoding.TextUnmarshaler.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Network
+// output=node_gen.go
+// errors=true
+type Network struct {
+	Subnet  string   // for testing netip.Prefix to string
+	Gateway []byte   // for testing netip.Addr to []byte
+	Peers   []string // for testing netip.Addr to string for slice elements
+
+	// mog: on-error=panic
+	Backup string // for testing *netip.Addr to string
+
+	// mog: on-error=ignore
+	Region string // for testing a text type with a pointer UnmarshalText method
+}
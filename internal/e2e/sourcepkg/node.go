@@ -4,6 +4,8 @@
 package sourcepkg
 
 import (
+	"time"
+
 	"github.com/hashicorp/mog/internal/e2e/core"
 	"github.com/hashicorp/mog/internal/e2e/core/inner"
 )
@@ -61,12 +63,36 @@ type Node struct {
 	M7 map[string]*Workload
 	M8 map[string]Workload
 
+	// mog: elem-func-to=int elem-func-from=int32
+	E1 []int32 // for testing user functions on slice elements
+	// mog: elem-func-to=core.Label elem-func-from=string
+	E2 map[string]string // for testing user functions on map values
+
+	// mog: direction=from
+	CreateIndex uint64 // for testing fields only assigned in one direction
+
+	// mog: nil-pointer=keep
+	P1 *Workload // for testing nil pointers which leave the target unchanged
+	// mog: nil-pointer=func:defaultWeight
+	P2 *int64 // for testing nil pointers which assign a default
+
+	// mog: default=30
+	Timeout int // for testing defaults for zero values
+	// mog: default=core.DefaultPartition
+	Partition *string // for testing defaults for nil pointers
+	// mog: default=(time.Minute * 2)
+	Grace time.Duration // for testing defaults with spaces
+
 	// S1 Workload  // for testing struct-to-struct for slices
 	// S2 *Workload // for testing ptr-to-ptr for slices
 	// S3 *Workload // for testing ptr-to-struct for slices
 	// S4 Workload  // for testing struct-to-ptr for slices
 }
 
+func defaultWeight() int64 {
+	return 1
+}
+
 type StringSlice []string
 
 type WorkloadSlice []Workload
@@ -76,8 +102,45 @@ type WorkloadSlice []Workload
 // name=Core
 // target=github.com/hashicorp/mog/internal/e2e/core.Workload
 // output=node_gen.go
+// constructors=true
 type Workload struct {
 	ID string
 	// mog: func-to=int func-from=int32
 	Value int32
 }
+
+// Status is a read-only projection of the core type.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Status
+// output=node_gen.go
+// direction=from
+type Status struct {
+	Healthy bool
+	// mog: func-from=string
+	Reason string
+}
+
+// Snapshot is converted without sharing any memory with the core type.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Snapshot
+// output=node_gen.go
+// copy=deep
+type Snapshot struct {
+	Tags    []string
+	Meta    map[string]interface{}
+	Owner   *core.Other
+	Weights []*int
+	Count   int // for testing struct-to-ptr copies
+
+	// mog: copy=shallow
+	Shared []string // for testing a field which opts out of copying
+
+	// mog: nil-collections=allocate
+	Labels map[string]string // for testing nil converted to an empty map
+}
@@ -0,0 +1,21 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package sourcepkg
+
+import "encoding/json"
+
+// Payload source structure for testing conversions of bytes.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Payload
+// output=node_gen.go
+type Payload struct {
+	Value  []byte            // for testing string to []byte
+	Raw    string            // for testing json.RawMessage to string
+	Config json.RawMessage   // for testing []byte to json.RawMessage
+	Chunks []string          // for testing []byte to string for slice elements
+	Values map[string][]byte // for testing string to []byte for map values
+}
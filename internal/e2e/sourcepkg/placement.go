@@ -0,0 +1,45 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package sourcepkg
+
+import (
+	"strconv"
+
+	"github.com/hashicorp/mog/internal/e2e/core"
+	"github.com/hashicorp/mog/internal/e2e/core/inner"
+)
+
+// Placement source structure for testing conversion methods found on the
+// types of the fields.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Placement
+// output=node_gen.go
+// errors=true
+// method-to=ToCore
+// method-from=ToInner
+type Placement struct {
+	// mog: direction=to
+	Rack Rack // for testing Rack.ToCore
+
+	// mog: direction=to
+	Racks []Rack // for testing Rack.ToCore for slice elements
+
+	// mog: direction=from
+	Zone inner.Zone // for testing core.Zone.ToInner which returns an error
+
+	// mog: direction=from
+	Zones map[string]inner.Zone // for testing core.Zone.ToInner for map values
+}
+
+type Rack struct {
+	Name string
+	Row  int
+}
+
+func (r Rack) ToCore() core.Rack {
+	return core.Rack{ID: r.Name + "-" + strconv.Itoa(r.Row)}
+}
@@ -0,0 +1,28 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package sourcepkg
+
+import (
+	"time"
+)
+
+// Schedule source structure for testing conversions of well-known types.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Schedule
+// output=node_gen.go
+// errors=true
+type Schedule struct {
+	Timeout   string        // for testing time.Duration to string
+	Interval  time.Duration // for testing time.Duration to int64
+	CreatedAt string        // for testing time.Time to string
+	UpdatedAt int64         // for testing time.Time to unix seconds
+	Expires   time.Time     // for testing string to time.Time
+	Address   string        // for testing net.IP to string
+	Endpoint  string        // for testing *url.URL to string
+	Homepage  string        // for testing url.URL to string
+	Retries   []string      // for testing time.Duration to string for slice elements
+}
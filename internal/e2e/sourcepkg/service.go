@@ -0,0 +1,99 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package sourcepkg
+
+import (
+	"context"
+	"net"
+	"strconv"
+)
+
+// Service source structure for e2e testing mog with conversion functions that
+// return errors and take a ctx.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Service
+// output=node_gen.go
+// errors=true
+// ctx=context.Context
+// constructors=true
+type Service struct {
+	Name string
+
+	// mog: func-to=normalizeNamespace func-from=normalizeNamespace
+	Namespace string
+
+	Primary   Endpoint
+	Endpoints []Endpoint
+	ByName    map[string]*Endpoint
+
+	// mog: elem-func-to=parsePort elem-func-from=formatPort
+	Ports []string
+
+	// mog: enum=name enum-map=ServiceKind_Gateway:ServiceKindMeshGateway
+	Kind ServiceKind
+}
+
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Endpoint
+// output=node_gen.go
+// errors=true
+// ctx=context.Context
+// methods=true
+type Endpoint struct {
+	// mog: func-to=parseIP func-from=formatIP
+	Address string
+
+	// mog: enum=order enum-fallback=error
+	Protocol Protocol
+}
+
+// ServiceKind is an int32 enum like those generated by protoc.
+type ServiceKind int32
+
+const (
+	ServiceKind_Typical      ServiceKind = 0
+	ServiceKind_ConnectProxy ServiceKind = 1
+	ServiceKind_Gateway      ServiceKind = 2
+)
+
+type Protocol int32
+
+const (
+	Protocol_TCP Protocol = 0
+	Protocol_UDP Protocol = 1
+)
+
+type namespaceKey struct{}
+
+func normalizeNamespace(ctx context.Context, ns string) string {
+	if ns == "" {
+		ns, _ = ctx.Value(namespaceKey{}).(string)
+	}
+	return ns
+}
+
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: s}
+	}
+	return ip, nil
+}
+
+func formatIP(ip net.IP) string {
+	return ip.String()
+}
+
+func formatPort(p int) string {
+	return strconv.Itoa(p)
+}
+
+func parsePort(s string) (int, error) {
+	return strconv.Atoi(s)
+}
@@ -0,0 +1,35 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package sourcepkg
+
+import (
+	"github.com/hashicorp/mog/internal/e2e/core"
+	"github.com/hashicorp/mog/internal/e2e/core/inner"
+)
+
+// Site source structure for testing conversion functions found by their
+// signature.
+//
+// mog annotation:
+//
+// name=Core
+// target=github.com/hashicorp/mog/internal/e2e/core.Site
+// output=node_gen.go
+type Site struct {
+	Coordinates Coordinates // for testing functions in the source package
+	Tag         inner.Tag   // for testing functions in the helper packages
+}
+
+type Coordinates struct {
+	Lat float64
+	Lng float64
+}
+
+func newCoreCoordinates(c Coordinates) core.Coordinates {
+	return core.Coordinates{Latitude: c.Lat, Longitude: c.Lng}
+}
+
+func coordinatesFromCore(c core.Coordinates) Coordinates {
+	return Coordinates{Lat: c.Latitude, Lng: c.Longitude}
+}
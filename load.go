@@ -26,7 +26,12 @@ type sourcePkg struct {
 	// Name of the package as it appears in the source file.
 	Name string
 
-	// TODO: buildTags string
+	// BuildTags are the build tags used to load the package.
+	BuildTags string
+
+	// Glob is the pattern which selected the files of the package that were
+	// loaded, when the source path has one.
+	Glob string
 
 	// Structs declared in the source package.
 	Structs map[string]structDecl
@@ -44,6 +49,15 @@ type typedField struct {
 	Var   *types.Var
 }
 
+// PkgPath returns the import path of the package, or an empty string if the
+// package was not loaded.
+func (p sourcePkg) PkgPath() string {
+	if p.pkg == nil {
+		return ""
+	}
+	return p.pkg.PkgPath
+}
+
 // StructNames returns a sorted slice of all the structs in the package.
 func (p sourcePkg) StructNames() []string {
 	names := make([]string, 0, len(p.Structs))
@@ -56,19 +70,32 @@ func (p sourcePkg) StructNames() []string {
 
 type handlePkgLoadErr func(pkg *packages.Package) error
 
-// loadSourceStructs scans the provided package for struct definitions that
-// have mog annotations.
-func loadSourceStructs(path string, tags string, handleErr handlePkgLoadErr) (sourcePkg, error) {
-	p := sourcePkg{Structs: map[string]structDecl{}}
-	cfg := &packages.Config{
-		Mode: modeLoadAll,
-	}
+// buildTags returns the build tags used to load packages, which are read from
+// the GOTAGS environment variable when tags is empty.
+func buildTags(tags string) string {
 	if tags == "" {
-		tags = os.Getenv("GOTAGS")
+		return os.Getenv("GOTAGS")
 	}
-	if tags != "" {
+	return tags
+}
+
+// newPackagesConfig returns the config used to load packages with the mode and
+// build tags.
+func newPackagesConfig(mode packages.LoadMode, tags string) *packages.Config {
+	cfg := &packages.Config{Mode: mode}
+	if tags = buildTags(tags); tags != "" {
 		cfg.BuildFlags = []string{fmt.Sprintf("-tags=%s", tags)}
 	}
+	return cfg
+}
+
+// loadSourceStructs scans the provided package for struct definitions that
+// have mog annotations.
+func loadSourceStructs(path string, tags string, handleErr handlePkgLoadErr) (sourcePkg, error) {
+	p := sourcePkg{Structs: map[string]structDecl{}}
+	tags = buildTags(tags)
+	cfg := newPackagesConfig(modeLoadAll, tags)
+	p.BuildTags = tags
 
 	var glob string
 	if strings.Contains(path, "*") {
@@ -114,17 +141,37 @@ func loadSourceStructs(path string, tags string, handleErr handlePkgLoadErr) (so
 		}
 	}
 
-	pkgs, err := packages.Load(cfg, path)
-	switch {
-	case err != nil:
+	load := func() (*packages.Package, error) {
+		pkgs, err := packages.Load(cfg, path)
+		switch {
+		case err != nil:
+			return nil, err
+		case len(pkgs) == 0:
+			return nil, fmt.Errorf("package not found")
+		case len(pkgs) > 1:
+			return nil, fmt.Errorf("expected only one source package")
+		}
+		return pkgs[0], nil
+	}
+
+	pkg, err := load()
+	if err != nil {
 		return p, err
-	case len(pkgs) == 0:
-		return p, fmt.Errorf("package not found")
-	case len(pkgs) > 1:
-		return p, fmt.Errorf("expected only one source package")
 	}
 
-	pkg := pkgs[0]
+	// Generated files which no longer compile, because the types they convert
+	// have changed, are replaced or removed when the code is generated, so
+	// the package is loaded without them.
+	if stale := staleGeneratedFiles(pkg); len(stale) > 0 {
+		cfg.Overlay = make(map[string][]byte, len(stale))
+		for _, filename := range stale {
+			cfg.Overlay[filename] = emptyGoFile(pkg.Name)
+		}
+		if pkg, err = load(); err != nil {
+			return p, err
+		}
+	}
+
 	if err := handleErr(pkg); err != nil {
 		return p, err
 	}
@@ -133,6 +180,7 @@ func loadSourceStructs(path string, tags string, handleErr handlePkgLoadErr) (so
 	}
 	p.Path = filepath.Dir(pkg.GoFiles[0])
 	p.Name = pkg.Name
+	p.Glob = glob
 	p.pkg = pkg
 
 	fieldVars := make(map[string]map[string]*types.Var)
@@ -216,6 +264,279 @@ func loadSourceStructs(path string, tags string, handleErr handlePkgLoadErr) (so
 	return p, nil
 }
 
+// lookup resolves a name as it would be referenced from a file in the source
+// package. The name may be qualified by the name of a package imported by
+// one of the files in the source package. Returns nil if the name could not
+// be resolved.
+func (p sourcePkg) lookup(name string) types.Object {
+	if p.pkg == nil || p.pkg.Types == nil {
+		return nil
+	}
+
+	i := strings.Index(name, ".")
+	if i == -1 {
+		if obj := p.pkg.Types.Scope().Lookup(name); obj != nil {
+			return obj
+		}
+		return types.Universe.Lookup(name)
+	}
+
+	imported := p.importedPkg(name[:i])
+	if imported == nil {
+		return nil
+	}
+	return imported.Scope().Lookup(name[i+1:])
+}
+
+// importedPkg returns the package imported by one of the files in the package
+// using the qualifier, or nil if there is no such import.
+func (p sourcePkg) importedPkg(qualifier string) *types.Package {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return nil
+	}
+	for _, file := range p.pkg.Syntax {
+		scope := p.pkg.TypesInfo.Scopes[file]
+		if scope == nil {
+			continue
+		}
+		pkgName, ok := scope.Lookup(qualifier).(*types.PkgName)
+		if !ok {
+			continue
+		}
+		return pkgName.Imported()
+	}
+	return nil
+}
+
+// fileScope returns the scope of the file in the source package which
+// contains pos, or nil if there is no such file.
+func (p sourcePkg) fileScope(pos token.Pos) *types.Scope {
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return nil
+	}
+	for _, file := range p.pkg.Syntax {
+		if file.FileStart <= pos && pos <= file.FileEnd {
+			return p.pkg.TypesInfo.Scopes[file]
+		}
+	}
+	return nil
+}
+
+// exprImports returns the path of the packages referred to by the expression,
+// keyed by the qualifier used in the expression. The qualifiers are resolved
+// using the imports of the file which contains pos.
+func (p sourcePkg) exprImports(expr ast.Expr, pos token.Pos) map[string]string {
+	scope := p.fileScope(pos)
+	if scope == nil {
+		return nil
+	}
+
+	var result map[string]string
+	ast.Inspect(expr, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if pkgName, ok := scope.Lookup(ident.Name).(*types.PkgName); ok {
+			if result == nil {
+				result = make(map[string]string)
+			}
+			result[ident.Name] = pkgName.Imported().Path()
+		}
+		return true
+	})
+	return result
+}
+
+// evalExpr type checks the expression as if it were at pos in the source
+// package, and returns its type, and its value when it is a constant. Returns
+// a nil type if the package was not type checked.
+func (p sourcePkg) evalExpr(expr string, pos token.Pos) (types.TypeAndValue, error) {
+	if p.pkg == nil || p.pkg.Types == nil {
+		return types.TypeAndValue{}, nil
+	}
+	return types.Eval(p.pkg.Fset, p.pkg.Types, pos, expr)
+}
+
+// userFuncObjs resolves the names of the user supplied functions to the
+// functions, or the types for type conversions, they refer to. Returns an
+// error if a name does not refer to a function or type.
+func (p sourcePkg) userFuncObjs(annotations []userFuncAnnotation) (map[string]types.Object, error) {
+	var result map[string]types.Object
+	for _, a := range annotations {
+		obj := p.lookup(a.Name)
+		switch obj.(type) {
+		case nil:
+			return nil, fmt.Errorf("uses %v=%v which is not declared in the source package", a.Key, a.Name)
+		case *types.Func, *types.TypeName:
+		default:
+			return nil, fmt.Errorf("uses %v=%v which is not a function or type", a.Key, a.Name)
+		}
+		if strings.Contains(a.Name, ".") && !obj.Exported() {
+			return nil, fmt.Errorf("uses %v=%v which is not exported", a.Key, a.Name)
+		}
+		if result == nil {
+			result = make(map[string]types.Object)
+		}
+		result[a.Name] = obj
+	}
+	return result, nil
+}
+
+// position returns the position in the source package, or the zero value if
+// the package was not loaded.
+func (p sourcePkg) position(pos token.Pos) token.Position {
+	if p.pkg == nil || p.pkg.Fset == nil {
+		return token.Position{}
+	}
+	return p.pkg.Fset.Position(pos)
+}
+
+// convertFuncs returns the functions declared in the source package which
+// can be used to convert fields. Functions in generated files are not
+// included, so the functions generated by a previous run are not used.
+func (p sourcePkg) convertFuncs() convertFuncs {
+	result := convertFuncs{}
+	if p.pkg == nil || p.pkg.TypesInfo == nil {
+		return result
+	}
+	for _, file := range p.pkg.Syntax {
+		if ast.IsGenerated(file) {
+			continue
+		}
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv != nil {
+				continue
+			}
+			if fn, ok := p.pkg.TypesInfo.Defs[funcDecl.Name].(*types.Func); ok {
+				result.Add(fn)
+			}
+		}
+	}
+	return result
+}
+
+// loadHelperFuncs adds the exported functions declared in the helper packages
+// which can be used to convert fields.
+func loadHelperFuncs(names []string, tags string, funcs convertFuncs) error {
+	mode := packages.NeedTypes | packages.NeedName | packages.NeedImports | packages.NeedDeps
+	cfg := newPackagesConfig(mode, tags)
+
+	pkgs, err := packages.Load(cfg, names...)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if err := packageLoadErrors(pkg); err != nil {
+			return err
+		}
+		scope := pkg.Types.Scope()
+		for _, name := range scope.Names() {
+			if fn, ok := scope.Lookup(name).(*types.Func); ok && fn.Exported() {
+				funcs.Add(fn)
+			}
+		}
+	}
+	return nil
+}
+
+// staleGeneratedFiles returns the files generated by mog which have type
+// errors, if all the type errors of the package are in generated files.
+func staleGeneratedFiles(pkg *packages.Package) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, typeErr := range pkg.TypeErrors {
+		filename := typeErr.Fset.Position(typeErr.Pos).Filename
+		if seen[filename] {
+			continue
+		}
+		seen[filename] = true
+
+		contents, err := os.ReadFile(filename)
+		if err != nil || !isMogGenerated(contents) {
+			return nil
+		}
+		result = append(result, filename)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// emptyGoFile returns the contents of a file in the package which has no
+// declarations. It is used in an overlay in place of a file that should not be
+// loaded.
+func emptyGoFile(pkgName string) []byte {
+	return []byte("package " + pkgName + "\n")
+}
+
+// checkGeneratedFiles type checks the source package with the generated files
+// in place of the files on disk, and without the removed files. Returns an
+// error for every type error in the generated or removed files, with the
+// struct and field that caused it when the error is in a generated file. Type
+// errors in other files are not caused by the generated code, so they are
+// ignored, and the other errors from loading the package are passed to
+// handleErr.
+func checkGeneratedFiles(p sourcePkg, files []generatedFile, removed []string, handleErr handlePkgLoadErr) error {
+	cfg := newPackagesConfig(modeLoadAll, p.BuildTags)
+	cfg.Overlay = make(map[string][]byte, len(files)+len(removed))
+	byPath := make(map[string]generatedFile, len(files))
+	for _, file := range files {
+		cfg.Overlay[file.Path] = file.Contents
+		byPath[file.Path] = file
+	}
+	for _, filename := range removed {
+		cfg.Overlay[filename] = emptyGoFile(p.Name)
+	}
+
+	pkgs, err := packages.Load(cfg, p.Path)
+	if err != nil {
+		return fmt.Errorf("failed to type check generated code: %w", err)
+	}
+
+	var errs []error
+	for _, pkg := range pkgs {
+		for _, typeErr := range pkg.TypeErrors {
+			pos := typeErr.Fset.Position(typeErr.Pos)
+			if _, ok := cfg.Overlay[pos.Filename]; !ok {
+				continue
+			}
+			file, ok := byPath[pos.Filename]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%v: %v", pos, typeErr.Msg))
+				continue
+			}
+			errs = append(errs, typeErrorFor(file, pos, typeErr.Msg))
+		}
+
+		loadErrs := *pkg
+		loadErrs.Errors = nil
+		for _, pkgErr := range pkg.Errors {
+			if pkgErr.Kind != packages.TypeError {
+				loadErrs.Errors = append(loadErrs.Errors, pkgErr)
+			}
+		}
+		if err := handleErr(&loadErrs); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return fmtErrors("generated code does not compile", errs)
+}
+
+// returnsError returns true if the last result of the function signature is
+// an error.
+func returnsError(sig *types.Signature) bool {
+	n := sig.Results().Len()
+	if n == 0 {
+		return false
+	}
+	return types.Identical(sig.Results().At(n-1).Type(), types.Universe.Lookup("error").Type())
+}
+
 // TODO: trim this if All isn't needed
 var modeLoadAll = packages.NeedName |
 	packages.NeedFiles |
@@ -296,16 +617,8 @@ type targetStruct struct {
 }
 
 func loadTargetStructs(names []string, tags string) (map[string]targetPkg, error) {
-	mode := packages.NeedTypes | packages.NeedTypesInfo | packages.NeedName
-	cfg := &packages.Config{
-		Mode: mode,
-	}
-	if tags == "" {
-		tags = os.Getenv("GOTAGS")
-	}
-	if tags != "" {
-		cfg.BuildFlags = []string{fmt.Sprintf("-tags=%s", tags)}
-	}
+	mode := packages.NeedTypes | packages.NeedTypesInfo | packages.NeedName | packages.NeedImports | packages.NeedDeps
+	cfg := newPackagesConfig(mode, tags)
 
 	pkgs, err := packages.Load(cfg, names...)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"golang.org/x/tools/go/packages"
 )
@@ -37,6 +38,9 @@ type options struct {
 	source                  string
 	ignorePackageLoadErrors bool
 	tags                    string
+	helperPackages          string
+	force                   bool
+	check                   bool
 }
 
 func (o options) handlePackageLoadErrors(pkg *packages.Package) error {
@@ -60,6 +64,15 @@ func setupFlags(name string) (*flag.FlagSet, *options) {
 	// TODO: make this a positional arg, set a Usage func to document it
 	flags.StringVar(&opts.tags, "tags", ".", "build tags to be passed when parsing the packages")
 
+	flags.StringVar(&opts.helperPackages, "helper-packages", "",
+		"comma separated package paths with functions used to convert fields")
+
+	flags.BoolVar(&opts.force, "force", false,
+		"overwrite output files which were not generated by mog")
+
+	flags.BoolVar(&opts.check, "check", false,
+		"report generated files which are out of date without writing or removing any files")
+
 	flags.BoolVar(&opts.ignorePackageLoadErrors, "ignore-package-load-errors", false,
 		"ignore any syntax errors encountered while loading source")
 	return flags, opts
@@ -80,9 +93,16 @@ func runMog(opts options) error {
 		return fmt.Errorf("failed to parse annotations: %w", err)
 	}
 
+	writeOpts := writeOptions{
+		Force:            opts.force,
+		Check:            opts.check,
+		HandlePkgLoadErr: opts.handlePackageLoadErrors,
+	}
 	if len(cfg.Structs) == 0 {
 		log.Printf("no source structs found in %v", opts.source)
-		return nil
+		// Files generated for structs which no longer have annotations are
+		// only removed with -force.
+		return generateFiles(cfg, nil, writeOpts)
 	}
 
 	targets, err := loadTargetStructs(targetPackages(cfg.Structs), opts.tags)
@@ -90,11 +110,21 @@ func runMog(opts options) error {
 		return fmt.Errorf("failed to load targets: %w", err)
 	}
 
-	cfg.Structs = applyAutoConvertFunctions(cfg.Structs)
+	cfg.Structs = applyAutoConvertFunctions(cfg.Structs, targets)
+
+	funcs := sources.convertFuncs()
+	if helpers := helperPackages(opts.helperPackages); len(helpers) > 0 {
+		if err := loadHelperFuncs(helpers, opts.tags, funcs); err != nil {
+			return fmt.Errorf("failed to load helper packages: %w", err)
+		}
+	}
+	for i := range cfg.Structs {
+		cfg.Structs[i].Funcs = funcs
+	}
 
 	log.Printf("Generating code for %d structs", len(cfg.Structs))
 
-	return generateFiles(cfg, targets)
+	return generateFiles(cfg, targets, writeOpts)
 }
 
 func targetPackages(cfgs []structConfig) []string {
@@ -107,3 +137,13 @@ func targetPackages(cfgs []structConfig) []string {
 	}
 	return result
 }
+
+func helperPackages(value string) []string {
+	var result []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -40,6 +41,37 @@ func TestE2E_NoSourcesFound(t *testing.T) {
 	assert.ErrorType(t, err, os.IsNotExist)
 }
 
+func TestE2E_NoSourcesFound_KeepsGeneratedFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("e2e test too slow for -short")
+	}
+
+	sourcepkg := "./internal/e2e/sourcepkg-empty"
+	generated := "./internal/e2e/sourcepkg-empty/node_gen.go"
+	t.Cleanup(func() {
+		os.Remove(generated)
+	})
+
+	contents := []byte(generatedHeader + "package sourcepkg_empty\n")
+	assert.NilError(t, os.WriteFile(generated, contents, 0644))
+
+	args := []string{"mog", "-source", sourcepkg}
+	err := run(args)
+	assert.ErrorContains(t, err, "refusing to remove generated files because no annotated structs were found")
+	_, err = os.Stat(generated)
+	assert.NilError(t, err)
+
+	err = run(append(args, "-check"))
+	assert.ErrorContains(t, err, "node_gen.go is orphaned and would be removed")
+	_, err = os.Stat(generated)
+	assert.NilError(t, err)
+
+	err = run(append(args, "-force"))
+	assert.NilError(t, err)
+	_, err = os.Stat(generated)
+	assert.ErrorType(t, err, os.IsNotExist)
+}
+
 func TestE2E(t *testing.T) {
 	if testing.Short() {
 		t.Skip("e2e test too slow for -short")
@@ -54,7 +86,7 @@ func TestE2E(t *testing.T) {
 		os.Remove(output)
 	})
 
-	args := []string{"mog", "-source", sourcepkg}
+	args := []string{"mog", "-source", sourcepkg, "-helper-packages", "./internal/e2e/helpers"}
 	err := run(args)
 	assert.NilError(t, err)
 
@@ -72,6 +104,163 @@ func TestE2E(t *testing.T) {
 	golden.Assert(t, string(actual), t.Name()+"-expected-node_gen.go")
 }
 
+func TestE2E_RefuseOverwrite(t *testing.T) {
+	if testing.Short() {
+		t.Skip("e2e test too slow for -short")
+	}
+
+	sourcepkg := "./internal/e2e/sourcepkg"
+	output := "./internal/e2e/sourcepkg/node_gen.go"
+	t.Cleanup(func() {
+		os.Remove(output)
+	})
+
+	handWritten := []byte("package sourcepkg\n")
+	assert.NilError(t, os.WriteFile(output, handWritten, 0644))
+
+	args := []string{"mog", "-source", sourcepkg, "-helper-packages", "./internal/e2e/helpers"}
+	err := run(args)
+	assert.ErrorContains(t, err, "node_gen.go was not generated by mog")
+
+	actual, err := os.ReadFile(output)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, handWritten)
+
+	err = run(append(args, "-force"))
+	assert.NilError(t, err)
+
+	actual, err = os.ReadFile(output)
+	assert.NilError(t, err)
+	assert.Assert(t, isMogGenerated(actual))
+}
+
+func TestE2E_OrphanedFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("e2e test too slow for -short")
+	}
+
+	sourcepkg := "./internal/e2e/sourcepkg"
+	output := "./internal/e2e/sourcepkg/node_gen.go"
+	// The orphan no longer compiles, because the struct it converts was
+	// renamed.
+	orphan := "./internal/e2e/sourcepkg/old_gen.go"
+	t.Cleanup(func() {
+		os.Remove(output)
+		os.Remove(orphan)
+	})
+
+	contents := []byte(generatedHeader + "package sourcepkg\n\nfunc OldNodeToCore(s *OldNode) {}\n")
+	assert.NilError(t, os.WriteFile(orphan, contents, 0644))
+
+	args := []string{"mog", "-source", sourcepkg, "-helper-packages", "./internal/e2e/helpers"}
+	err := run(append(args, "-check"))
+	assert.ErrorContains(t, err, "old_gen.go is orphaned and would be removed")
+
+	_, err = os.Stat(orphan)
+	assert.NilError(t, err)
+	_, err = os.Stat(output)
+	assert.ErrorType(t, err, os.IsNotExist)
+
+	err = run(args)
+	assert.NilError(t, err)
+
+	_, err = os.Stat(orphan)
+	assert.ErrorType(t, err, os.IsNotExist)
+	_, err = os.Stat(output)
+	assert.NilError(t, err)
+}
+
+func TestE2E_Unchanged(t *testing.T) {
+	if testing.Short() {
+		t.Skip("e2e test too slow for -short")
+	}
+
+	sourcepkg := "./internal/e2e/sourcepkg"
+	output := "./internal/e2e/sourcepkg/node_gen.go"
+	t.Cleanup(func() {
+		os.Remove(output)
+	})
+
+	args := []string{"mog", "-source", sourcepkg, "-helper-packages", "./internal/e2e/helpers"}
+	err := run(append(args, "-check"))
+	assert.ErrorContains(t, err, "node_gen.go is out of date and would be created")
+
+	assert.NilError(t, run(args))
+	before, err := os.Stat(output)
+	assert.NilError(t, err)
+
+	// The file is up to date, so it is not written again.
+	assert.NilError(t, run(append(args, "-check")))
+	assert.NilError(t, run(args))
+	after, err := os.Stat(output)
+	assert.NilError(t, err)
+	assert.Equal(t, after.ModTime(), before.ModTime())
+}
+
+func TestE2E_TypeErrors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("e2e test too slow for -short")
+	}
+
+	// Type check a copy of the source package, so that the broken file below
+	// is never written into the source tree. The copy is in a module nested
+	// under this one, which it replaces, so it can still import the internal
+	// e2e packages.
+	dir := t.TempDir()
+	copySourcePkg(t, "./internal/e2e/sourcepkg", filepath.Join(dir, "sourcepkg"))
+	root, err := filepath.Abs(".")
+	assert.NilError(t, err)
+	gomod := fmt.Sprintf("module github.com/hashicorp/mog/internal/e2e/typeerrors\n\ngo 1.24\n\n"+
+		"require github.com/hashicorp/mog v0.0.0\n\n"+
+		"replace github.com/hashicorp/mog => %v\n", root)
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(gomod), 0644))
+	gosum, err := os.ReadFile("go.sum")
+	assert.NilError(t, err)
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "go.sum"), gosum, 0644))
+	t.Chdir(dir)
+
+	source, err := loadSourceStructs("./sourcepkg", "", packageLoadErrors)
+	assert.NilError(t, err)
+
+	cfg := structConfig{
+		Source: "Node",
+		Fields: []fieldConfig{{SourceName: "ID", TargetName: "ID"}},
+	}
+	file := generatedFile{
+		Path: filepath.Join(source.Path, "node_gen.go"),
+		Contents: []byte(generatedHeader + `package sourcepkg
+
+func NodeToCore(s *Node, t *Node) {
+	t.ID = 1
+}
+`),
+		Structs: []structConfig{cfg},
+	}
+	err = checkGeneratedFiles(source, []generatedFile{file}, nil, packageLoadErrors)
+	assert.ErrorContains(t, err, "struct Node field ID does not compile: cannot use 1")
+	assert.ErrorContains(t, err, "node_gen.go:6:9)")
+
+	// Type errors in other files are not caused by the generated code.
+	broken := filepath.Join(source.Path, "broken.go")
+	assert.NilError(t, os.WriteFile(broken, []byte("package sourcepkg\n\nvar broken int = \"\"\n"), 0644))
+	file.Contents = []byte(generatedHeader + "package sourcepkg\n")
+	err = checkGeneratedFiles(source, []generatedFile{file}, nil, packageLoadErrors)
+	assert.NilError(t, err)
+}
+
+// copySourcePkg copies the Go files of the package in src to the dst directory.
+func copySourcePkg(t *testing.T, src, dst string) {
+	t.Helper()
+	assert.NilError(t, os.MkdirAll(dst, 0755))
+	filenames, err := filepath.Glob(filepath.Join(src, "*.go"))
+	assert.NilError(t, err)
+	for _, filename := range filenames {
+		contents, err := os.ReadFile(filename)
+		assert.NilError(t, err)
+		assert.NilError(t, os.WriteFile(filepath.Join(dst, filepath.Base(filename)), contents, 0644))
+	}
+}
+
 // PrependLineNumbers prepends line numbers onto the text passed in. In the
 // event of some parsing error it just returns the original input, unprefixed.
 func PrependLineNumbers(s string) string {
@@ -34,6 +34,10 @@ type singleAssignmentKind struct {
 
 	// Convert implies that a simple type conversion is required.
 	Convert bool
+
+	// Builtin is the conversion between well-known types, if neither Direct
+	// nor Convert are set and one is needed.
+	Builtin *builtinConversion
 }
 
 var _ assignmentKind = (*singleAssignmentKind)(nil)
@@ -47,6 +51,9 @@ func (o *singleAssignmentKind) String() string {
 	if o.Convert {
 		s += " (convert)"
 	}
+	if o.Builtin != nil {
+		s += " (builtin)"
+	}
 	return s
 }
 
@@ -76,6 +83,10 @@ type sliceAssignmentKind struct {
 	// ElemConvert implies that a simple type conversion is required for
 	// elements of the slice.
 	ElemConvert bool
+
+	// ElemBuiltin is the conversion between well-known types used for
+	// elements of the slice, if any.
+	ElemBuiltin *builtinConversion
 }
 
 var _ assignmentKind = (*sliceAssignmentKind)(nil)
@@ -94,6 +105,9 @@ func (o *sliceAssignmentKind) String() string {
 	if o.ElemConvert {
 		s += " (convert)"
 	}
+	if o.ElemBuiltin != nil {
+		s += " (builtin)"
+	}
 	return s
 }
 
@@ -132,6 +146,10 @@ type mapAssignmentKind struct {
 	// ElemConvert implies that a simple type conversion is required for
 	// elements of the map.
 	ElemConvert bool
+
+	// ElemBuiltin is the conversion between well-known types used for
+	// elements of the map, if any.
+	ElemBuiltin *builtinConversion
 }
 
 var _ assignmentKind = (*mapAssignmentKind)(nil)
@@ -152,6 +170,9 @@ func (o *mapAssignmentKind) String() string {
 	if o.ElemConvert {
 		s += " (convert)"
 	}
+	if o.ElemBuiltin != nil {
+		s += " (builtin)"
+	}
 	return s
 }
 
@@ -174,16 +195,55 @@ func convertibleButNotIdentical(typ, typeDecode types.Type) bool {
 	return false
 }
 
+// isRuneConversion returns true if one of the types is an integer and the
+// other a string. Go converts an integer to the string of the rune it encodes,
+// not its digits, so they are never converted with a type conversion.
+func isRuneConversion(leftType, rightType types.Type) bool {
+	isInteger := func(t types.Type) bool {
+		basic, ok := t.Underlying().(*types.Basic)
+		return ok && basic.Info()&types.IsInteger != 0
+	}
+	isString := func(t types.Type) bool {
+		basic, ok := t.Underlying().(*types.Basic)
+		return ok && basic.Info()&types.IsString != 0
+	}
+	return (isInteger(leftType) && isString(rightType)) || (isString(leftType) && isInteger(rightType))
+}
+
 // computeAssignment attempts to determine how to assign something of the
-// rightType to something of the leftType.
+// rightType to something of the leftType. The types only have to be
+// assignable or convertible in the directions the field is assigned in, where
+// DirTo assigns the right to the left, and DirFrom the left to the right.
 //
 // If this is not possible, or not currently supported (nil, false) is
 // returned.
-func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
-	// First check if the types are naturally directly assignable. Only allow
-	// type pairs that are symmetrically assignable for simplicity.
-	if types.AssignableTo(rightType, leftType) {
-		if !types.AssignableTo(leftType, rightType) {
+func computeAssignment(leftType, rightType types.Type, dirs []Direction) (assignmentKind, bool) {
+	// Well-known types are converted with a builtin conversion, which has to
+	// be checked before the types are decoded because time.Duration would be
+	// convertible to a string, and before checking if the types are
+	// assignable because json.RawMessage and []byte are copied.
+	if builtin, ok := lookupBuiltinConversion(leftType, rightType); ok {
+		return &singleAssignmentKind{
+			Left:    leftType,
+			Right:   rightType,
+			Builtin: builtin,
+		}, true
+	}
+
+	// Types which implement the text marshaling interfaces are converted to
+	// and from strings and []byte using them, unless they are convertible.
+	if text, ok := lookupTextConversion(leftType, rightType); ok {
+		return &singleAssignmentKind{
+			Left:    leftType,
+			Right:   rightType,
+			Builtin: text,
+		}, true
+	}
+
+	// Then check if the types are naturally directly assignable. Only allow
+	// type pairs that are assignable in every direction for simplicity.
+	if types.AssignableTo(rightType, leftType) || types.AssignableTo(leftType, rightType) {
+		if !checkDirections(dirs, leftType, rightType, types.AssignableTo) {
 			return nil, false
 		}
 		return &singleAssignmentKind{
@@ -204,6 +264,12 @@ func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
 	if convertibleButNotIdentical(rightType, rightTypeDecode) ||
 		convertibleButNotIdentical(leftType, leftTypeDecode) {
 
+		if isRuneConversion(leftTypeDecode, rightTypeDecode) {
+			return nil, false
+		}
+		if !checkDirections(dirs, leftTypeDecode, rightTypeDecode, types.ConvertibleTo) {
+			return nil, false
+		}
 		return &singleAssignmentKind{
 			Left:    leftType,
 			Right:   rightType,
@@ -214,10 +280,24 @@ func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
 	switch left := leftTypeDecode.(type) {
 	case *types.Basic:
 		// basic can only assign to basic
-		_, ok := rightTypeDecode.(*types.Basic)
+		right, ok := rightTypeDecode.(*types.Basic)
 		if !ok {
 			return nil, false
 		}
+		// Different basic types, like int32 and int64, need a type conversion.
+		if !isPointer(leftType) && !isPointer(rightType) && !types.Identical(left, right) {
+			if isRuneConversion(left, right) {
+				return nil, false
+			}
+			if !checkDirections(dirs, left, right, types.ConvertibleTo) {
+				return nil, false
+			}
+			return &singleAssignmentKind{
+				Left:    leftType,
+				Right:   rightType,
+				Convert: true,
+			}, true
+		}
 		return &singleAssignmentKind{
 			Left:   leftType,
 			Right:  rightType,
@@ -241,7 +321,7 @@ func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
 		}
 
 		// the elements have to be assignable
-		rawOp, ok := computeAssignment(left.Elem(), right.Elem())
+		rawOp, ok := computeAssignment(left.Elem(), right.Elem(), dirs)
 		if !ok {
 			return nil, false
 		}
@@ -258,6 +338,7 @@ func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
 			RightElem:   right.Elem(),
 			ElemDirect:  op.Direct,
 			ElemConvert: op.Convert,
+			ElemBuiltin: op.Builtin,
 		}, true
 	case *types.Map:
 		right, ok := rightTypeDecode.(*types.Map)
@@ -265,7 +346,7 @@ func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
 			return nil, false
 		}
 
-		rawKeyOp, ok := computeAssignment(left.Key(), right.Key())
+		rawKeyOp, ok := computeAssignment(left.Key(), right.Key(), dirs)
 		if !ok {
 			return nil, false
 		}
@@ -280,7 +361,7 @@ func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
 		}
 
 		// the map values have to be assignable
-		rawOp, ok := computeAssignment(left.Elem(), right.Elem())
+		rawOp, ok := computeAssignment(left.Elem(), right.Elem(), dirs)
 		if !ok {
 			return nil, false
 		}
@@ -299,8 +380,116 @@ func computeAssignment(leftType, rightType types.Type) (assignmentKind, bool) {
 			RightElem:   right.Elem(),
 			ElemDirect:  op.Direct,
 			ElemConvert: op.Convert,
+			ElemBuiltin: op.Builtin,
 		}, true
 	}
 
 	return nil, false
 }
+
+// checkDirections returns true if check is true for every direction, where
+// DirTo assigns a value of the rightType to the leftType, and DirFrom a value
+// of the leftType to the rightType.
+func checkDirections(dirs []Direction, leftType, rightType types.Type, check func(v, t types.Type) bool) bool {
+	for _, dir := range dirs {
+		from, to := rightType, leftType
+		if dir == DirFrom {
+			from, to = to, from
+		}
+		if !check(from, to) {
+			return false
+		}
+	}
+	return true
+}
+
+// computeElemFuncAssignment attempts to determine how to assign something of
+// the rightType to something of the leftType when the elements are converted
+// using a user supplied function. Only the container types are checked, the
+// elements are assumed to be handled by the function.
+//
+// If this is not possible, or not currently supported (nil, false) is
+// returned.
+func computeElemFuncAssignment(leftType, rightType types.Type, dirs []Direction) (assignmentKind, bool) {
+	leftTypeDecode, leftOk := decodeType(leftType)
+	rightTypeDecode, rightOk := decodeType(rightType)
+	if !leftOk || !rightOk {
+		return nil, false
+	}
+
+	switch left := leftTypeDecode.(type) {
+	case *types.Slice:
+		right, ok := rightTypeDecode.(*types.Slice)
+		if !ok {
+			return nil, false
+		}
+		return &sliceAssignmentKind{
+			Left:      leftType,
+			LeftElem:  left.Elem(),
+			Right:     rightType,
+			RightElem: right.Elem(),
+		}, true
+	case *types.Map:
+		right, ok := rightTypeDecode.(*types.Map)
+		if !ok {
+			return nil, false
+		}
+
+		// the map keys have to be directly assignable
+		rawKeyOp, ok := computeAssignment(left.Key(), right.Key(), dirs)
+		if !ok {
+			return nil, false
+		}
+		keyOp, ok := rawKeyOp.(*singleAssignmentKind)
+		if !ok || !keyOp.Direct {
+			return nil, false
+		}
+
+		return &mapAssignmentKind{
+			Left:      leftType,
+			LeftKey:   left.Key(),
+			LeftElem:  left.Elem(),
+			Right:     rightType,
+			RightKey:  right.Key(),
+			RightElem: right.Elem(),
+		}, true
+	}
+
+	return nil, false
+}
+
+// deepCopyAssignment returns the assignment of each element of a slice or map
+// in place of the direct assignment of the whole slice or map, so that the
+// target does not share the backing array or map with the source. Other kinds
+// of assignment are returned unchanged.
+func deepCopyAssignment(rawKind assignmentKind) assignmentKind {
+	kind, ok := rawKind.(*singleAssignmentKind)
+	if !ok || !kind.Direct {
+		return rawKind
+	}
+
+	// Directly assignable types have identical underlying types.
+	switch left := kind.Left.Underlying().(type) {
+	case *types.Slice:
+		right := kind.Right.Underlying().(*types.Slice)
+		return &sliceAssignmentKind{
+			Left:       kind.Left,
+			LeftElem:   left.Elem(),
+			Right:      kind.Right,
+			RightElem:  right.Elem(),
+			ElemDirect: true,
+		}
+	case *types.Map:
+		right := kind.Right.Underlying().(*types.Map)
+		return &mapAssignmentKind{
+			Left:       kind.Left,
+			LeftKey:    left.Key(),
+			LeftElem:   left.Elem(),
+			Right:      kind.Right,
+			RightKey:   right.Key(),
+			RightElem:  right.Elem(),
+			ElemDirect: true,
+		}
+	}
+	return rawKind
+}
@@ -0,0 +1,138 @@
+// Copyright IBM Corp. 2020, 2025
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"fmt"
+	"go/types"
+)
+
+// lossyMode is how a numeric conversion which may lose data is assigned.
+type lossyMode string
+
+const (
+	// lossyAllow assigns the converted value, which may be truncated or
+	// wrapped.
+	lossyAllow lossyMode = "allow"
+
+	// lossyCheck returns an error when the converted value is not equal to
+	// the original value.
+	lossyCheck lossyMode = "check"
+)
+
+// numericClass is the class of a conversion between two numeric types.
+type numericClass string
+
+const (
+	// numericWidening converts to a type which can hold every value of the
+	// original type.
+	numericWidening numericClass = "widening"
+
+	// numericNarrowing converts to a smaller type of the same kind.
+	numericNarrowing numericClass = "narrowing"
+
+	// numericSignChange converts between signed and unsigned integers, where
+	// the new type can not hold every value of the original type.
+	numericSignChange numericClass = "sign change"
+
+	// numericFloatToInt converts a float to an integer, which discards the
+	// fraction.
+	numericFloatToInt numericClass = "float to int"
+
+	// numericIntToFloat converts an integer to a float which can not
+	// represent every value of the integer exactly.
+	numericIntToFloat numericClass = "int to float"
+)
+
+// numericSizes are the sizes used to classify conversions of int, uint and
+// uintptr, which are assumed to be 64 bits.
+var numericSizes = types.SizesFor("gc", "amd64")
+
+// numericConversion is a conversion from one numeric type to another.
+type numericConversion struct {
+	To   *types.Basic
+	From *types.Basic
+}
+
+// newNumericConversion returns the conversion from one type to the other, if
+// both types are integers or floats.
+func newNumericConversion(to, from types.Type) (numericConversion, bool) {
+	toBasic, ok := numericBasic(to)
+	if !ok {
+		return numericConversion{}, false
+	}
+	fromBasic, ok := numericBasic(from)
+	if !ok {
+		return numericConversion{}, false
+	}
+	return numericConversion{To: toBasic, From: fromBasic}, true
+}
+
+func numericBasic(t types.Type) (*types.Basic, bool) {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok || basic.Info()&(types.IsInteger|types.IsFloat) == 0 {
+		return nil, false
+	}
+	return basic, true
+}
+
+// Class returns the class of the conversion.
+func (c numericConversion) Class() numericClass {
+	toSize := numericSizes.Sizeof(c.To)
+	fromSize := numericSizes.Sizeof(c.From)
+
+	switch {
+	case isFloat(c.From) && isFloat(c.To):
+		if toSize < fromSize {
+			return numericNarrowing
+		}
+		return numericWidening
+	case isFloat(c.From):
+		return numericFloatToInt
+	case isFloat(c.To):
+		// The mantissa of a float32 has 24 bits, and of a float64 53 bits.
+		mantissa := int64(24)
+		if toSize == 8 {
+			mantissa = 53
+		}
+		if fromSize*8 > mantissa {
+			return numericIntToFloat
+		}
+		return numericWidening
+	case toSize < fromSize:
+		return numericNarrowing
+	case isUnsigned(c.From) == isUnsigned(c.To):
+		return numericWidening
+	case isUnsigned(c.From) && toSize > fromSize:
+		return numericWidening
+	}
+	return numericSignChange
+}
+
+// Lossy returns true if the converted value may not be equal to the original
+// value.
+func (c numericConversion) Lossy() bool {
+	return c.Class() != numericWidening
+}
+
+// SignChange returns true if a negative value could be converted to a
+// positive value, or the other way around.
+func (c numericConversion) SignChange() bool {
+	if isFloat(c.From) || isFloat(c.To) {
+		return false
+	}
+	return isUnsigned(c.From) != isUnsigned(c.To)
+}
+
+func (c numericConversion) String() string {
+	return fmt.Sprintf("%v to %v", c.From, c.To)
+}
+
+func isFloat(t *types.Basic) bool {
+	return t.Info()&types.IsFloat != 0
+}
+
+func isUnsigned(t *types.Basic) bool {
+	return t.Info()&types.IsUnsigned != 0
+}
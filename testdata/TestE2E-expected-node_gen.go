@@ -2,15 +2,240 @@
 
 package sourcepkg
 
-import "github.com/hashicorp/mog/internal/e2e/core"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/mog/internal/e2e/core"
+	"github.com/hashicorp/mog/internal/e2e/core/inner"
+	"github.com/hashicorp/mog/internal/e2e/helpers"
+	"net"
+	"net/netip"
+	"net/url"
+	"time"
+)
 
+func (s *Endpoint) ToCore(ctx context.Context) (*core.Endpoint, error) {
+	if s == nil {
+		return nil, nil
+	}
+	t := new(core.Endpoint)
+	{
+		x, err := parseIP(s.Address)
+		if err != nil {
+			return nil, fmt.Errorf("Address: %w", err)
+		}
+		t.Address = x
+	}
+	switch s.Protocol {
+	case Protocol_TCP:
+		t.Protocol = core.ProtocolTCP
+	case Protocol_UDP:
+		t.Protocol = core.ProtocolUDP
+	default:
+		err := fmt.Errorf("unknown value %v", s.Protocol)
+		return nil, fmt.Errorf("Protocol: %w", err)
+	}
+	return t, nil
+}
+func (s *Endpoint) FromCore(ctx context.Context, t *core.Endpoint) error {
+	if s == nil {
+		return nil
+	}
+	s.Address = formatIP(t.Address)
+	switch t.Protocol {
+	case core.ProtocolTCP:
+		s.Protocol = Protocol_TCP
+	case core.ProtocolUDP:
+		s.Protocol = Protocol_UDP
+	default:
+		err := fmt.Errorf("unknown value %v", t.Protocol)
+		return fmt.Errorf("Protocol: %w", err)
+	}
+	return nil
+}
+func LimitsToCore(s *Limits, t *core.Limits) error {
+	if s == nil {
+		return nil
+	}
+	t.MaxConns = int64(s.MaxConns)
+	{
+		x := int32(s.Attempts)
+		if int64(x) != s.Attempts {
+			err := fmt.Errorf("%v can not be converted to int32 without loss", s.Attempts)
+			return fmt.Errorf("Attempts: %w", err)
+		}
+		t.Attempts = x
+	}
+	{
+		x := uint16(s.Port)
+		if int32(x) != s.Port || s.Port < 0 {
+			err := fmt.Errorf("%v can not be converted to uint16 without loss", s.Port)
+			return fmt.Errorf("Port: %w", err)
+		}
+		t.Port = x
+	}
+	t.Ratio = float32(s.Ratio)
+	if s.Offsets != nil {
+		t.Offsets = make([]uint64, len(s.Offsets))
+		for i := range s.Offsets {
+			{
+				x := uint64(s.Offsets[i])
+				if int(x) != s.Offsets[i] || s.Offsets[i] < 0 {
+					err := fmt.Errorf("%v can not be converted to uint64 without loss", s.Offsets[i])
+					return fmt.Errorf("Offsets[%d]: %w", i, err)
+				}
+				t.Offsets[i] = x
+			}
+		}
+	} else {
+		t.Offsets = nil
+	}
+	return nil
+}
+func LimitsFromCore(t *core.Limits, s *Limits) error {
+	if s == nil {
+		return nil
+	}
+	s.Attempts = int64(t.Attempts)
+	s.Port = int32(t.Port)
+	s.Ratio = float64(t.Ratio)
+	if t.Offsets != nil {
+		s.Offsets = make([]int, len(t.Offsets))
+		for i := range t.Offsets {
+			{
+				x := int(t.Offsets[i])
+				if uint64(x) != t.Offsets[i] || x < 0 {
+					err := fmt.Errorf("%v can not be converted to int without loss", t.Offsets[i])
+					return fmt.Errorf("Offsets[%d]: %w", i, err)
+				}
+				s.Offsets[i] = x
+			}
+		}
+	} else {
+		s.Offsets = nil
+	}
+	return nil
+}
+func NetworkToCore(s *Network, t *core.Network) error {
+	if s == nil {
+		return nil
+	}
+	if s.Subnet != "" {
+		var x netip.Prefix
+		if err := x.UnmarshalText([]byte(s.Subnet)); err != nil {
+			return fmt.Errorf("Subnet: %w", err)
+		}
+		t.Subnet = x
+	} else {
+		var x netip.Prefix
+		t.Subnet = x
+	}
+	if len(s.Gateway) != 0 {
+		var x netip.Addr
+		if err := x.UnmarshalText(s.Gateway); err != nil {
+			return fmt.Errorf("Gateway: %w", err)
+		}
+		t.Gateway = x
+	} else {
+		var x netip.Addr
+		t.Gateway = x
+	}
+	if s.Peers != nil {
+		t.Peers = make([]netip.Addr, len(s.Peers))
+		for i := range s.Peers {
+			if s.Peers[i] != "" {
+				var x netip.Addr
+				if err := x.UnmarshalText([]byte(s.Peers[i])); err != nil {
+					return fmt.Errorf("Peers[%d]: %w", i, err)
+				}
+				t.Peers[i] = x
+			} else {
+				var x netip.Addr
+				t.Peers[i] = x
+			}
+		}
+	} else {
+		t.Peers = nil
+	}
+	if s.Backup != "" {
+		var x netip.Addr
+		if err := x.UnmarshalText([]byte(s.Backup)); err != nil {
+			panic(fmt.Errorf("Backup: %w", err))
+		}
+		t.Backup = &x
+	} else {
+		t.Backup = nil
+	}
+	if s.Region != "" {
+		var x core.Region
+		_ = x.UnmarshalText([]byte(s.Region))
+		t.Region = x
+	} else {
+		var x core.Region
+		t.Region = x
+	}
+	return nil
+}
+func NetworkFromCore(t *core.Network, s *Network) error {
+	if s == nil {
+		return nil
+	}
+	{
+		x, err := t.Subnet.MarshalText()
+		if err != nil {
+			return fmt.Errorf("Subnet: %w", err)
+		}
+		s.Subnet = string(x)
+	}
+	{
+		x, err := t.Gateway.MarshalText()
+		if err != nil {
+			return fmt.Errorf("Gateway: %w", err)
+		}
+		s.Gateway = x
+	}
+	if t.Peers != nil {
+		s.Peers = make([]string, len(t.Peers))
+		for i := range t.Peers {
+			{
+				x, err := t.Peers[i].MarshalText()
+				if err != nil {
+					return fmt.Errorf("Peers[%d]: %w", i, err)
+				}
+				s.Peers[i] = string(x)
+			}
+		}
+	} else {
+		s.Peers = nil
+	}
+	if t.Backup != nil {
+		x, err := t.Backup.MarshalText()
+		if err != nil {
+			panic(fmt.Errorf("Backup: %w", err))
+		}
+		s.Backup = string(x)
+	} else {
+		var x string
+		s.Backup = x
+	}
+	{
+		x, _ := t.Region.MarshalText()
+		s.Region = string(x)
+	}
+	return nil
+}
 func NodeToCore(s *Node, t *core.ClusterNode) {
 	if s == nil {
 		return
 	}
 	t.ID = s.ID
 	t.Label = core.Label(s.Label)
-	t.Flag = &s.Flag
+	{
+		x := s.Flag
+		t.Flag = &x
+	}
 	if s.Number != nil {
 		t.Number = *s.Number
 	} else {
@@ -24,9 +249,14 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 		var x core.Workload
 		WorkloadToCore(s.F2, &x)
 		t.F2 = &x
+	} else {
+		t.F2 = nil
 	}
 	if s.F3 != nil {
 		WorkloadToCore(s.F3, &t.F3)
+	} else {
+		var x core.Workload
+		t.F3 = x
 	}
 	{
 		var x core.Workload
@@ -35,7 +265,7 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 	}
 	t.S1 = s.S1
 	t.S2 = s.S2
-	{
+	if s.S3 != nil {
 		t.S3 = make([]string, len(s.S3))
 		for i := range s.S3 {
 			if s.S3[i] != nil {
@@ -45,38 +275,56 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 				t.S3[i] = x
 			}
 		}
+	} else {
+		t.S3 = nil
 	}
-	{
+	if s.S4 != nil {
 		t.S4 = make([]*string, len(s.S4))
 		for i := range s.S4 {
-			t.S4[i] = &s.S4[i]
+			{
+				x := s.S4[i]
+				t.S4[i] = &x
+			}
 		}
+	} else {
+		t.S4 = nil
 	}
-	{
+	if s.S5 != nil {
 		t.S5 = make([]core.Workload, len(s.S5))
 		for i := range s.S5 {
 			WorkloadToCore(&s.S5[i], &t.S5[i])
 		}
+	} else {
+		t.S5 = nil
 	}
-	{
+	if s.S6 != nil {
 		t.S6 = make([]*core.Workload, len(s.S6))
 		for i := range s.S6 {
 			if s.S6[i] != nil {
 				var x core.Workload
 				WorkloadToCore(s.S6[i], &x)
 				t.S6[i] = &x
+			} else {
+				t.S6[i] = nil
 			}
 		}
+	} else {
+		t.S6 = nil
 	}
-	{
+	if s.S7 != nil {
 		t.S7 = make([]core.Workload, len(s.S7))
 		for i := range s.S7 {
 			if s.S7[i] != nil {
 				WorkloadToCore(s.S7[i], &t.S7[i])
+			} else {
+				var x core.Workload
+				t.S7[i] = x
 			}
 		}
+	} else {
+		t.S7 = nil
 	}
-	{
+	if s.S8 != nil {
 		t.S8 = make([]*core.Workload, len(s.S8))
 		for i := range s.S8 {
 			{
@@ -85,10 +333,12 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 				t.S8[i] = &x
 			}
 		}
+	} else {
+		t.S8 = nil
 	}
 	t.S9 = s.S9
 	t.S10 = s.S10
-	{
+	if s.S11 != nil {
 		t.S11 = make(core.WorkloadSlice, len(s.S11))
 		for i := range s.S11 {
 			{
@@ -97,8 +347,10 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 				t.S11[i] = &x
 			}
 		}
+	} else {
+		t.S11 = nil
 	}
-	{
+	if s.S12 != nil {
 		t.S12 = make([]*core.Workload, len(s.S12))
 		for i := range s.S12 {
 			{
@@ -107,8 +359,10 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 				t.S12[i] = &x
 			}
 		}
+	} else {
+		t.S12 = nil
 	}
-	{
+	if s.S13 != nil {
 		t.S13 = make(core.WorkloadSlice, len(s.S13))
 		for i := range s.S13 {
 			{
@@ -117,10 +371,12 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 				t.S13[i] = &x
 			}
 		}
+	} else {
+		t.S13 = nil
 	}
 	t.M1 = s.M1
 	t.M2 = s.M2
-	{
+	if s.M3 != nil {
 		t.M3 = make(map[string]string, len(s.M3))
 		for k, v := range s.M3 {
 			var y string
@@ -132,24 +388,33 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 			}
 			t.M3[k] = y
 		}
+	} else {
+		t.M3 = nil
 	}
-	{
+	if s.M4 != nil {
 		t.M4 = make(map[string]*string, len(s.M4))
 		for k, v := range s.M4 {
 			var y *string
-			y = &v
+			{
+				x := v
+				y = &x
+			}
 			t.M4[k] = y
 		}
+	} else {
+		t.M4 = nil
 	}
-	{
+	if s.M5 != nil {
 		t.M5 = make(map[string]core.Workload, len(s.M5))
 		for k, v := range s.M5 {
 			var y core.Workload
 			WorkloadToCore(&v, &y)
 			t.M5[k] = y
 		}
+	} else {
+		t.M5 = nil
 	}
-	{
+	if s.M6 != nil {
 		t.M6 = make(map[string]*core.Workload, len(s.M6))
 		for k, v := range s.M6 {
 			var y *core.Workload
@@ -157,21 +422,30 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 				var x core.Workload
 				WorkloadToCore(v, &x)
 				y = &x
+			} else {
+				y = nil
 			}
 			t.M6[k] = y
 		}
+	} else {
+		t.M6 = nil
 	}
-	{
+	if s.M7 != nil {
 		t.M7 = make(map[string]core.Workload, len(s.M7))
 		for k, v := range s.M7 {
 			var y core.Workload
 			if v != nil {
 				WorkloadToCore(v, &y)
+			} else {
+				var x core.Workload
+				y = x
 			}
 			t.M7[k] = y
 		}
+	} else {
+		t.M7 = nil
 	}
-	{
+	if s.M8 != nil {
 		t.M8 = make(map[string]*core.Workload, len(s.M8))
 		for k, v := range s.M8 {
 			var y *core.Workload
@@ -182,6 +456,47 @@ func NodeToCore(s *Node, t *core.ClusterNode) {
 			}
 			t.M8[k] = y
 		}
+	} else {
+		t.M8 = nil
+	}
+	if s.E1 != nil {
+		t.E1 = make([]int, len(s.E1))
+		for i := range s.E1 {
+			t.E1[i] = int(s.E1[i])
+		}
+	} else {
+		t.E1 = nil
+	}
+	if s.E2 != nil {
+		t.E2 = make(map[string]core.Label, len(s.E2))
+		for k, v := range s.E2 {
+			t.E2[k] = core.Label(v)
+		}
+	} else {
+		t.E2 = nil
+	}
+	if s.P1 != nil {
+		WorkloadToCore(s.P1, &t.P1)
+	}
+	if s.P2 != nil {
+		t.P2 = *s.P2
+	} else {
+		t.P2 = defaultWeight()
+	}
+	if s.Timeout == 0 {
+		t.Timeout = 30
+	} else {
+		t.Timeout = s.Timeout
+	}
+	if s.Partition != nil {
+		t.Partition = *s.Partition
+	} else {
+		t.Partition = core.DefaultPartition
+	}
+	if s.Grace == 0 {
+		t.Grace = time.Minute * 2
+	} else {
+		t.Grace = time.Duration(s.Grace)
 	}
 }
 func NodeFromCore(t *core.ClusterNode, s *Node) {
@@ -196,7 +511,10 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 		var x bool
 		s.Flag = x
 	}
-	s.Number = &t.Number
+	{
+		x := t.Number
+		s.Number = &x
+	}
 	s.O = t.O
 	s.I = t.I
 	WorkloadFromCore(&t.F1, &s.F1)
@@ -204,6 +522,8 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 		var x Workload
 		WorkloadFromCore(t.F2, &x)
 		s.F2 = &x
+	} else {
+		s.F2 = nil
 	}
 	{
 		var x Workload
@@ -212,16 +532,24 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 	}
 	if t.F4 != nil {
 		WorkloadFromCore(t.F4, &s.F4)
+	} else {
+		var x Workload
+		s.F4 = x
 	}
 	s.S1 = t.S1
 	s.S2 = t.S2
-	{
+	if t.S3 != nil {
 		s.S3 = make([]*string, len(t.S3))
 		for i := range t.S3 {
-			s.S3[i] = &t.S3[i]
+			{
+				x := t.S3[i]
+				s.S3[i] = &x
+			}
 		}
+	} else {
+		s.S3 = nil
 	}
-	{
+	if t.S4 != nil {
 		s.S4 = make([]string, len(t.S4))
 		for i := range t.S4 {
 			if t.S4[i] != nil {
@@ -231,24 +559,32 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 				s.S4[i] = x
 			}
 		}
+	} else {
+		s.S4 = nil
 	}
-	{
+	if t.S5 != nil {
 		s.S5 = make([]Workload, len(t.S5))
 		for i := range t.S5 {
 			WorkloadFromCore(&t.S5[i], &s.S5[i])
 		}
+	} else {
+		s.S5 = nil
 	}
-	{
+	if t.S6 != nil {
 		s.S6 = make([]*Workload, len(t.S6))
 		for i := range t.S6 {
 			if t.S6[i] != nil {
 				var x Workload
 				WorkloadFromCore(t.S6[i], &x)
 				s.S6[i] = &x
+			} else {
+				s.S6[i] = nil
 			}
 		}
+	} else {
+		s.S6 = nil
 	}
-	{
+	if t.S7 != nil {
 		s.S7 = make([]*Workload, len(t.S7))
 		for i := range t.S7 {
 			{
@@ -257,52 +593,79 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 				s.S7[i] = &x
 			}
 		}
+	} else {
+		s.S7 = nil
 	}
-	{
+	if t.S8 != nil {
 		s.S8 = make([]Workload, len(t.S8))
 		for i := range t.S8 {
 			if t.S8[i] != nil {
 				WorkloadFromCore(t.S8[i], &s.S8[i])
+			} else {
+				var x Workload
+				s.S8[i] = x
 			}
 		}
+	} else {
+		s.S8 = nil
 	}
 	s.S9 = t.S9
 	s.S10 = t.S10
-	{
+	if t.S11 != nil {
 		s.S11 = make([]Workload, len(t.S11))
 		for i := range t.S11 {
 			if t.S11[i] != nil {
 				WorkloadFromCore(t.S11[i], &s.S11[i])
+			} else {
+				var x Workload
+				s.S11[i] = x
 			}
 		}
+	} else {
+		s.S11 = nil
 	}
-	{
+	if t.S12 != nil {
 		s.S12 = make(WorkloadSlice, len(t.S12))
 		for i := range t.S12 {
 			if t.S12[i] != nil {
 				WorkloadFromCore(t.S12[i], &s.S12[i])
+			} else {
+				var x Workload
+				s.S12[i] = x
 			}
 		}
+	} else {
+		s.S12 = nil
 	}
-	{
+	if t.S13 != nil {
 		s.S13 = make(WorkloadSlice, len(t.S13))
 		for i := range t.S13 {
 			if t.S13[i] != nil {
 				WorkloadFromCore(t.S13[i], &s.S13[i])
+			} else {
+				var x Workload
+				s.S13[i] = x
 			}
 		}
+	} else {
+		s.S13 = nil
 	}
 	s.M1 = t.M1
 	s.M2 = t.M2
-	{
+	if t.M3 != nil {
 		s.M3 = make(map[string]*string, len(t.M3))
 		for k, v := range t.M3 {
 			var y *string
-			y = &v
+			{
+				x := v
+				y = &x
+			}
 			s.M3[k] = y
 		}
+	} else {
+		s.M3 = nil
 	}
-	{
+	if t.M4 != nil {
 		s.M4 = make(map[string]string, len(t.M4))
 		for k, v := range t.M4 {
 			var y string
@@ -314,16 +677,20 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 			}
 			s.M4[k] = y
 		}
+	} else {
+		s.M4 = nil
 	}
-	{
+	if t.M5 != nil {
 		s.M5 = make(map[string]Workload, len(t.M5))
 		for k, v := range t.M5 {
 			var y Workload
 			WorkloadFromCore(&v, &y)
 			s.M5[k] = y
 		}
+	} else {
+		s.M5 = nil
 	}
-	{
+	if t.M6 != nil {
 		s.M6 = make(map[string]*Workload, len(t.M6))
 		for k, v := range t.M6 {
 			var y *Workload
@@ -331,11 +698,15 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 				var x Workload
 				WorkloadFromCore(v, &x)
 				y = &x
+			} else {
+				y = nil
 			}
 			s.M6[k] = y
 		}
+	} else {
+		s.M6 = nil
 	}
-	{
+	if t.M7 != nil {
 		s.M7 = make(map[string]*Workload, len(t.M7))
 		for k, v := range t.M7 {
 			var y *Workload
@@ -346,17 +717,596 @@ func NodeFromCore(t *core.ClusterNode, s *Node) {
 			}
 			s.M7[k] = y
 		}
+	} else {
+		s.M7 = nil
 	}
-	{
+	if t.M8 != nil {
 		s.M8 = make(map[string]Workload, len(t.M8))
 		for k, v := range t.M8 {
 			var y Workload
 			if v != nil {
 				WorkloadFromCore(v, &y)
+			} else {
+				var x Workload
+				y = x
 			}
 			s.M8[k] = y
 		}
+	} else {
+		s.M8 = nil
+	}
+	if t.E1 != nil {
+		s.E1 = make([]int32, len(t.E1))
+		for i := range t.E1 {
+			s.E1[i] = int32(t.E1[i])
+		}
+	} else {
+		s.E1 = nil
+	}
+	if t.E2 != nil {
+		s.E2 = make(map[string]string, len(t.E2))
+		for k, v := range t.E2 {
+			s.E2[k] = string(v)
+		}
+	} else {
+		s.E2 = nil
+	}
+	s.CreateIndex = t.CreateIndex
+	{
+		var x Workload
+		WorkloadFromCore(&t.P1, &x)
+		s.P1 = &x
+	}
+	{
+		x := t.P2
+		s.P2 = &x
+	}
+	s.Timeout = t.Timeout
+	{
+		x := t.Partition
+		s.Partition = &x
+	}
+	s.Grace = time.Duration(t.Grace)
+}
+func PayloadToCore(s *Payload, t *core.Payload) {
+	if s == nil {
+		return
+	}
+	t.Value = string(s.Value)
+	if s.Raw != "" {
+		t.Raw = json.RawMessage(s.Raw)
+	} else {
+		var x json.RawMessage
+		t.Raw = x
+	}
+	t.Config = bytes.Clone(s.Config)
+	if s.Chunks != nil {
+		t.Chunks = make([][]byte, len(s.Chunks))
+		for i := range s.Chunks {
+			if s.Chunks[i] != "" {
+				t.Chunks[i] = []byte(s.Chunks[i])
+			} else {
+				t.Chunks[i] = nil
+			}
+		}
+	} else {
+		t.Chunks = nil
+	}
+	if s.Values != nil {
+		t.Values = make(map[string]string, len(s.Values))
+		for k, v := range s.Values {
+			var y string
+			y = string(v)
+			t.Values[k] = y
+		}
+	} else {
+		t.Values = nil
+	}
+}
+func PayloadFromCore(t *core.Payload, s *Payload) {
+	if s == nil {
+		return
+	}
+	if t.Value != "" {
+		s.Value = []byte(t.Value)
+	} else {
+		s.Value = nil
+	}
+	s.Raw = string(t.Raw)
+	s.Config = bytes.Clone(t.Config)
+	if t.Chunks != nil {
+		s.Chunks = make([]string, len(t.Chunks))
+		for i := range t.Chunks {
+			s.Chunks[i] = string(t.Chunks[i])
+		}
+	} else {
+		s.Chunks = nil
+	}
+	if t.Values != nil {
+		s.Values = make(map[string][]byte, len(t.Values))
+		for k, v := range t.Values {
+			var y []byte
+			if v != "" {
+				y = []byte(v)
+			} else {
+				y = nil
+			}
+			s.Values[k] = y
+		}
+	} else {
+		s.Values = nil
+	}
+}
+func PlacementToCore(s *Placement, t *core.Placement) error {
+	if s == nil {
+		return nil
+	}
+	t.Rack = s.Rack.ToCore()
+	if s.Racks != nil {
+		t.Racks = make([]core.Rack, len(s.Racks))
+		for i := range s.Racks {
+			t.Racks[i] = s.Racks[i].ToCore()
+		}
+	} else {
+		t.Racks = nil
+	}
+	return nil
+}
+func PlacementFromCore(t *core.Placement, s *Placement) error {
+	if s == nil {
+		return nil
+	}
+	{
+		x, err := t.Zone.ToInner()
+		if err != nil {
+			return fmt.Errorf("Zone: %w", err)
+		}
+		s.Zone = x
+	}
+	if t.Zones != nil {
+		s.Zones = make(map[string]inner.Zone, len(t.Zones))
+		for k, v := range t.Zones {
+			{
+				x, err := v.ToInner()
+				if err != nil {
+					return fmt.Errorf("Zones[%v]: %w", k, err)
+				}
+				s.Zones[k] = x
+			}
+		}
+	} else {
+		s.Zones = nil
+	}
+	return nil
+}
+func ScheduleToCore(s *Schedule, t *core.Schedule) error {
+	if s == nil {
+		return nil
+	}
+	if s.Timeout != "" {
+		x, err := time.ParseDuration(s.Timeout)
+		if err != nil {
+			return fmt.Errorf("Timeout: %w", err)
+		}
+		t.Timeout = x
+	} else {
+		var x time.Duration
+		t.Timeout = x
+	}
+	t.Interval = int64(s.Interval)
+	if s.CreatedAt != "" {
+		x, err := time.Parse(time.RFC3339Nano, s.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("CreatedAt: %w", err)
+		}
+		t.CreatedAt = x
+	} else {
+		var x time.Time
+		t.CreatedAt = x
+	}
+	if s.UpdatedAt != 0 {
+		t.UpdatedAt = time.Unix(s.UpdatedAt, 0)
+	} else {
+		var x time.Time
+		t.UpdatedAt = x
+	}
+	if !s.Expires.IsZero() {
+		t.Expires = s.Expires.Format(time.RFC3339Nano)
+	} else {
+		var x string
+		t.Expires = x
+	}
+	if s.Address != "" {
+		t.Address = net.ParseIP(s.Address)
+	} else {
+		var x net.IP
+		t.Address = x
+	}
+	if s.Endpoint != "" {
+		x, err := url.Parse(s.Endpoint)
+		if err != nil {
+			return fmt.Errorf("Endpoint: %w", err)
+		}
+		t.Endpoint = x
+	} else {
+		t.Endpoint = nil
+	}
+	if s.Homepage != "" {
+		x, err := url.Parse(s.Homepage)
+		if err != nil {
+			return fmt.Errorf("Homepage: %w", err)
+		}
+		t.Homepage = *x
+	} else {
+		var x url.URL
+		t.Homepage = x
+	}
+	if s.Retries != nil {
+		t.Retries = make([]time.Duration, len(s.Retries))
+		for i := range s.Retries {
+			if s.Retries[i] != "" {
+				x, err := time.ParseDuration(s.Retries[i])
+				if err != nil {
+					return fmt.Errorf("Retries[%d]: %w", i, err)
+				}
+				t.Retries[i] = x
+			} else {
+				var x time.Duration
+				t.Retries[i] = x
+			}
+		}
+	} else {
+		t.Retries = nil
 	}
+	return nil
+}
+func ScheduleFromCore(t *core.Schedule, s *Schedule) error {
+	if s == nil {
+		return nil
+	}
+	if t.Timeout != 0 {
+		s.Timeout = t.Timeout.String()
+	} else {
+		var x string
+		s.Timeout = x
+	}
+	s.Interval = time.Duration(t.Interval)
+	if !t.CreatedAt.IsZero() {
+		s.CreatedAt = t.CreatedAt.Format(time.RFC3339Nano)
+	} else {
+		var x string
+		s.CreatedAt = x
+	}
+	if !t.UpdatedAt.IsZero() {
+		s.UpdatedAt = t.UpdatedAt.Unix()
+	} else {
+		var x int64
+		s.UpdatedAt = x
+	}
+	if t.Expires != "" {
+		x, err := time.Parse(time.RFC3339Nano, t.Expires)
+		if err != nil {
+			return fmt.Errorf("Expires: %w", err)
+		}
+		s.Expires = x
+	} else {
+		var x time.Time
+		s.Expires = x
+	}
+	if t.Address != nil {
+		s.Address = t.Address.String()
+	} else {
+		var x string
+		s.Address = x
+	}
+	if t.Endpoint != nil {
+		s.Endpoint = t.Endpoint.String()
+	} else {
+		var x string
+		s.Endpoint = x
+	}
+	s.Homepage = t.Homepage.String()
+	if t.Retries != nil {
+		s.Retries = make([]string, len(t.Retries))
+		for i := range t.Retries {
+			if t.Retries[i] != 0 {
+				s.Retries[i] = t.Retries[i].String()
+			} else {
+				var x string
+				s.Retries[i] = x
+			}
+		}
+	} else {
+		s.Retries = nil
+	}
+	return nil
+}
+func ServiceToCore(ctx context.Context, s *Service, t *core.Service) error {
+	if s == nil {
+		return nil
+	}
+	t.Name = s.Name
+	t.Namespace = normalizeNamespace(ctx, s.Namespace)
+	{
+		r, err := s.Primary.ToCore(ctx)
+		if err != nil {
+			return fmt.Errorf("Primary: %w", err)
+		}
+		t.Primary = *r
+	}
+	if s.Endpoints != nil {
+		t.Endpoints = make([]core.Endpoint, len(s.Endpoints))
+		for i := range s.Endpoints {
+			{
+				r, err := s.Endpoints[i].ToCore(ctx)
+				if err != nil {
+					return fmt.Errorf("Endpoints[%d]: %w", i, err)
+				}
+				t.Endpoints[i] = *r
+			}
+		}
+	} else {
+		t.Endpoints = nil
+	}
+	if s.ByName != nil {
+		t.ByName = make(map[string]*core.Endpoint, len(s.ByName))
+		for k, v := range s.ByName {
+			var y *core.Endpoint
+			if v != nil {
+				var x core.Endpoint
+				{
+					r, err := v.ToCore(ctx)
+					if err != nil {
+						return fmt.Errorf("ByName[%v]: %w", k, err)
+					}
+					x = *r
+				}
+				y = &x
+			} else {
+				y = nil
+			}
+			t.ByName[k] = y
+		}
+	} else {
+		t.ByName = nil
+	}
+	if s.Ports != nil {
+		t.Ports = make([]int, len(s.Ports))
+		for i := range s.Ports {
+			{
+				x, err := parsePort(s.Ports[i])
+				if err != nil {
+					return fmt.Errorf("Ports[%d]: %w", i, err)
+				}
+				t.Ports[i] = x
+			}
+		}
+	} else {
+		t.Ports = nil
+	}
+	switch s.Kind {
+	case ServiceKind_Typical:
+		t.Kind = core.ServiceKindTypical
+	case ServiceKind_ConnectProxy:
+		t.Kind = core.ServiceKindConnectProxy
+	case ServiceKind_Gateway:
+		t.Kind = core.ServiceKindMeshGateway
+	default:
+		var x core.ServiceKind
+		t.Kind = x
+	}
+	return nil
+}
+func ServiceFromCore(ctx context.Context, t *core.Service, s *Service) error {
+	if s == nil {
+		return nil
+	}
+	s.Name = t.Name
+	s.Namespace = normalizeNamespace(ctx, t.Namespace)
+	if err := s.Primary.FromCore(ctx, &t.Primary); err != nil {
+		return fmt.Errorf("Primary: %w", err)
+	}
+	if t.Endpoints != nil {
+		s.Endpoints = make([]Endpoint, len(t.Endpoints))
+		for i := range t.Endpoints {
+			if err := s.Endpoints[i].FromCore(ctx, &t.Endpoints[i]); err != nil {
+				return fmt.Errorf("Endpoints[%d]: %w", i, err)
+			}
+		}
+	} else {
+		s.Endpoints = nil
+	}
+	if t.ByName != nil {
+		s.ByName = make(map[string]*Endpoint, len(t.ByName))
+		for k, v := range t.ByName {
+			var y *Endpoint
+			if v != nil {
+				var x Endpoint
+				if err := x.FromCore(ctx, v); err != nil {
+					return fmt.Errorf("ByName[%v]: %w", k, err)
+				}
+				y = &x
+			} else {
+				y = nil
+			}
+			s.ByName[k] = y
+		}
+	} else {
+		s.ByName = nil
+	}
+	if t.Ports != nil {
+		s.Ports = make([]string, len(t.Ports))
+		for i := range t.Ports {
+			s.Ports[i] = formatPort(t.Ports[i])
+		}
+	} else {
+		s.Ports = nil
+	}
+	switch t.Kind {
+	case core.ServiceKindTypical:
+		s.Kind = ServiceKind_Typical
+	case core.ServiceKindConnectProxy:
+		s.Kind = ServiceKind_ConnectProxy
+	case core.ServiceKindMeshGateway:
+		s.Kind = ServiceKind_Gateway
+	default:
+		var x ServiceKind
+		s.Kind = x
+	}
+	return nil
+}
+func NewCoreFromService(ctx context.Context, s *Service) (*core.Service, error) {
+	if s == nil {
+		return nil, nil
+	}
+	var t core.Service
+	if err := ServiceToCore(ctx, s, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+func NewServiceFromCore(ctx context.Context, t *core.Service) (*Service, error) {
+	if t == nil {
+		return nil, nil
+	}
+	var s Service
+	if err := ServiceFromCore(ctx, t, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+func SiteToCore(s *Site, t *core.Site) {
+	if s == nil {
+		return
+	}
+	t.Coordinates = newCoreCoordinates(s.Coordinates)
+	t.Tag = helpers.FormatTag(s.Tag)
+}
+func SiteFromCore(t *core.Site, s *Site) {
+	if s == nil {
+		return
+	}
+	s.Coordinates = coordinatesFromCore(t.Coordinates)
+	s.Tag = helpers.ParseTag(t.Tag)
+}
+func SnapshotToCore(s *Snapshot, t *core.Snapshot) {
+	if s == nil {
+		return
+	}
+	if s.Tags != nil {
+		t.Tags = make([]string, len(s.Tags))
+		for i := range s.Tags {
+			t.Tags[i] = s.Tags[i]
+		}
+	} else {
+		t.Tags = nil
+	}
+	if s.Meta != nil {
+		t.Meta = make(map[string]any, len(s.Meta))
+		for k, v := range s.Meta {
+			var y any
+			y = v
+			t.Meta[k] = y
+		}
+	} else {
+		t.Meta = nil
+	}
+	if s.Owner != nil {
+		x := *s.Owner
+		t.Owner = &x
+	} else {
+		t.Owner = nil
+	}
+	if s.Weights != nil {
+		t.Weights = make([]*int, len(s.Weights))
+		for i := range s.Weights {
+			if s.Weights[i] != nil {
+				x := *s.Weights[i]
+				t.Weights[i] = &x
+			} else {
+				t.Weights[i] = nil
+			}
+		}
+	} else {
+		t.Weights = nil
+	}
+	{
+		x := s.Count
+		t.Count = &x
+	}
+	t.Shared = s.Shared
+	{
+		t.Labels = make(map[string]string, len(s.Labels))
+		for k, v := range s.Labels {
+			var y string
+			y = v
+			t.Labels[k] = y
+		}
+	}
+}
+func SnapshotFromCore(t *core.Snapshot, s *Snapshot) {
+	if s == nil {
+		return
+	}
+	if t.Tags != nil {
+		s.Tags = make([]string, len(t.Tags))
+		for i := range t.Tags {
+			s.Tags[i] = t.Tags[i]
+		}
+	} else {
+		s.Tags = nil
+	}
+	if t.Meta != nil {
+		s.Meta = make(map[string]interface{}, len(t.Meta))
+		for k, v := range t.Meta {
+			var y any
+			y = v
+			s.Meta[k] = y
+		}
+	} else {
+		s.Meta = nil
+	}
+	if t.Owner != nil {
+		x := *t.Owner
+		s.Owner = &x
+	} else {
+		s.Owner = nil
+	}
+	if t.Weights != nil {
+		s.Weights = make([]*int, len(t.Weights))
+		for i := range t.Weights {
+			if t.Weights[i] != nil {
+				x := *t.Weights[i]
+				s.Weights[i] = &x
+			} else {
+				s.Weights[i] = nil
+			}
+		}
+	} else {
+		s.Weights = nil
+	}
+	if t.Count != nil {
+		s.Count = *t.Count
+	} else {
+		var x int
+		s.Count = x
+	}
+	s.Shared = t.Shared
+	{
+		s.Labels = make(map[string]string, len(t.Labels))
+		for k, v := range t.Labels {
+			var y string
+			y = v
+			s.Labels[k] = y
+		}
+	}
+}
+func StatusFromCore(t *core.Status, s *Status) {
+	if s == nil {
+		return
+	}
+	s.Healthy = t.Healthy
+	s.Reason = string(t.Reason)
 }
 func WorkloadToCore(s *Workload, t *core.Workload) {
 	if s == nil {
@@ -372,3 +1322,19 @@ func WorkloadFromCore(t *core.Workload, s *Workload) {
 	s.ID = t.ID
 	s.Value = int32(t.Value)
 }
+func NewCoreFromWorkload(s *Workload) *core.Workload {
+	if s == nil {
+		return nil
+	}
+	var t core.Workload
+	WorkloadToCore(s, &t)
+	return &t
+}
+func NewWorkloadFromCore(t *core.Workload) *Workload {
+	if t == nil {
+		return nil
+	}
+	var s Workload
+	WorkloadFromCore(t, &s)
+	return &s
+}
@@ -38,24 +38,17 @@ func typeToExpr(t types.Type, imports *imports, element bool) (x ast.Expr) {
 		return &ast.Ident{Name: x.Name()}
 
 	case *types.Named:
-		targetTypeName := x.Obj()
-
-		pkgPath := targetTypeName.Pkg().Path()
-		if imports != nil {
-			pkgPath = imports.AliasFor(pkgPath)
-		}
-
-		pkg := path.Base(pkgPath)
-		if pkg == "" || pkg == "." { // package-scoped
-			return &ast.Ident{Name: targetTypeName.Name()}
-		}
-
-		sel := &ast.SelectorExpr{
-			X:   &ast.Ident{Name: pkg},
-			Sel: &ast.Ident{Name: targetTypeName.Name()},
+		return qualifiedName(x.Obj(), imports)
+
+	case *types.Alias:
+		// Aliases are referred to by their own name. json.RawMessage is an
+		// alias of jsontext.Value when encoding/json is built with the jsonv2
+		// experiment. Aliases in the universe scope, like any, have no
+		// package.
+		if x.Obj().Pkg() == nil {
+			return typeToExpr(types.Unalias(x), imports, element)
 		}
-
-		return sel
+		return qualifiedName(x.Obj(), imports)
 
 	case *types.Pointer:
 		actual := typeToExpr(x.Elem(), imports, element)
@@ -65,7 +58,9 @@ func typeToExpr(t types.Type, imports *imports, element bool) (x ast.Expr) {
 		return &ast.StarExpr{X: actual}
 
 	case *types.Slice:
-		if element {
+		// []byte elements are converted with a builtin conversion, which
+		// copies them.
+		if element && !types.Identical(x, types.NewSlice(types.Typ[types.Byte])) {
 			return nil
 		}
 		actual := typeToExpr(x.Elem(), imports, element)
@@ -89,10 +84,10 @@ func typeToExpr(t types.Type, imports *imports, element bool) (x ast.Expr) {
 		}
 
 	case *types.Interface: // needed to target map[string]interface{} at all
-		if element {
+		if element && !x.Empty() {
 			return nil
 		}
-		return &ast.InterfaceType{}
+		return &ast.Ident{Name: "any"}
 
 	}
 
@@ -137,3 +132,31 @@ func decodeType(t types.Type) (types.Type, bool) {
 func debugPrintType(t types.Type) string {
 	return fmt.Sprintf("[%T, %+v]", t, t)
 }
+
+// isPointer returns true if the type is a pointer.
+func isPointer(t types.Type) bool {
+	_, ok := t.(*types.Pointer)
+	return ok
+}
+
+// qualifiedName returns the expression which refers to the package level
+// object, adding its package to imports if necessary.
+func qualifiedName(obj types.Object, imports *imports) ast.Expr {
+	pkgPath := obj.Pkg().Path()
+	if imports != nil {
+		if imports.local != "" && pkgPath != imports.local {
+			imports.Add("", pkgPath)
+		}
+		pkgPath = imports.AliasFor(pkgPath)
+	}
+
+	pkg := path.Base(pkgPath)
+	if pkg == "" || pkg == "." { // package-scoped
+		return &ast.Ident{Name: obj.Name()}
+	}
+
+	return &ast.SelectorExpr{
+		X:   &ast.Ident{Name: pkg},
+		Sel: &ast.Ident{Name: obj.Name()},
+	}
+}